@@ -4,9 +4,63 @@ import "fmt"
 
 // Something that can be 'called'. Either a function or method.
 type Callable interface {
-	// The number of arguments expected by the Callable.
-	Arity() int
+	// The minimum number of arguments expected by the Callable.
+	MinArity() int
+	// The maximum number of arguments expected by the Callable, or -1 if it
+	// accepts any number of arguments.
+	MaxArity() int
 	// Executes the Callable.
 	Call(interpreter Interpreter, arguments []any) (any, error)
 	fmt.Stringer
 }
+
+// A Callable whose parameters can be passed by name, e.g. `f(b: 3)`.
+type NamedCallable interface {
+	Callable
+	// Names of the parameters in declaration order, including a trailing rest
+	// parameter if MaxArity is -1.
+	ParameterNames() []string
+}
+
+// Stands in for an argument that wasn't passed because a later parameter was
+// passed by name. The parameter gets its default value instead.
+type missingArgument struct{}
+
+// Error returned by a NativeFunction, or by another Callable that doesn't have
+// access to the call's tokens. The interpreter reports it at the call site.
+type NativeError struct {
+	Message string
+}
+
+func (e NativeError) Error() string {
+	return e.Message
+}
+
+// A function implemented in Go.
+type NativeFunction struct {
+	name     string
+	minArity int
+	maxArity int
+	function func(interpreter Interpreter, arguments []any) (any, error)
+}
+
+// Creates a native function that takes exactly arity arguments.
+func NewNativeFunction(name string, arity int, function func(interpreter Interpreter, arguments []any) (any, error)) *NativeFunction {
+	return &NativeFunction{name, arity, arity, function}
+}
+
+func (n *NativeFunction) MinArity() int {
+	return n.minArity
+}
+
+func (n *NativeFunction) MaxArity() int {
+	return n.maxArity
+}
+
+func (n *NativeFunction) Call(interpreter Interpreter, arguments []any) (any, error) {
+	return n.function(interpreter, arguments)
+}
+
+func (n *NativeFunction) String() string {
+	return "<native fn: " + n.name + ">"
+}
@@ -0,0 +1,677 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// A type known to the checker. Unannotated code has type Any, which is
+// compatible with every other type, so that it stays dynamically typed.
+type StaticType interface {
+	String() string
+}
+
+// Built-in types that can be named in annotations.
+type BasicType string
+
+const (
+	AnyType      BasicType = "Any"
+	NumberType   BasicType = "Number"
+	StringType   BasicType = "String"
+	BoolType     BasicType = "Bool"
+	NilType      BasicType = "Nil"
+	ListType     BasicType = "List"
+	CallableType BasicType = "Function"
+)
+
+func (t BasicType) String() string {
+	return string(t)
+}
+
+// Static type of a function or method.
+type Signature struct {
+	// Parameter types and names in declaration order.
+	Params []StaticType
+	Names  []string
+	// Same as Callable.MinArity and Callable.MaxArity.
+	MinArity int
+	MaxArity int
+	Returns  StaticType
+	// Type of each argument collected by a rest parameter.
+	Rest StaticType
+}
+
+func (s *Signature) String() string {
+	return "Function"
+}
+
+// Static type of a class. Instances of the class have type InstanceType.
+type ClassSignature struct {
+	Name       string
+	Superclass *ClassSignature
+	Methods    map[string]*Signature
+}
+
+func (c *ClassSignature) String() string {
+	return "Class"
+}
+
+// Mirrors Class.FindMethod.
+func (c *ClassSignature) FindMethod(name string) (*Signature, bool) {
+	for class := c; class != nil; class = class.Superclass {
+		if method, found := class.Methods[name]; found {
+			return method, true
+		}
+	}
+	return nil, false
+}
+
+// Static type of a trait. Traits can't be used as annotations.
+type TraitSignature struct {
+	Methods map[string]*Signature
+}
+
+func (t *TraitSignature) String() string {
+	return "Trait"
+}
+
+// Static type of instances of a class, named by the class in annotations.
+type InstanceType struct {
+	Class *ClassSignature
+}
+
+func (t InstanceType) String() string {
+	return t.Class.Name
+}
+
+// Whether a value of type value can be stored where target is expected.
+func isAssignable(target, value StaticType) bool {
+	if target == AnyType || value == AnyType {
+		return true
+	}
+	switch target := target.(type) {
+	case BasicType:
+		if target == CallableType {
+			switch value.(type) {
+			case *Signature, *ClassSignature:
+				return true
+			}
+		}
+		return target == value
+	case InstanceType:
+		value, ok := value.(InstanceType)
+		if !ok {
+			return false
+		}
+		for class := value.Class; class != nil; class = class.Superclass {
+			if class == target.Class {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Checker verifies type annotations for `glox check`. It infers the types of
+// literals and operators, and checks declarations, assignments, returns and
+// calls to functions and classes it knows about against the annotations.
+// Mismatches are reported like other compile-time errors. Like Resolver, it
+// walks the AST once, after resolution.
+type Checker struct {
+	// Types of the variables in each scope. The first scope holds globals.
+	scopes []map[string]StaticType
+	// Declared return type of the function being checked, or nil at the top
+	// level.
+	currentReturn StaticType
+	// Class whose methods are being checked, if any.
+	currentClass *ClassSignature
+}
+
+func NewChecker() *Checker {
+	return &Checker{
+		scopes: []map[string]StaticType{{}},
+	}
+}
+
+func (c *Checker) checkAll(statements []Stmt) {
+	c.declareAll(statements)
+	for _, statement := range statements {
+		statement.AcceptStmt(c)
+	}
+}
+
+// Declares the functions and classes in a list of statements up front, so
+// that calls to them can be checked before their declaration, e.g. in mutual
+// recursion. Classes are declared first so that annotations can name them.
+func (c *Checker) declareAll(statements []Stmt) {
+	var classes []ClassStmt
+	var traits []TraitStmt
+	for _, statement := range statements {
+		switch statement := statement.(type) {
+		case ClassStmt:
+			c.define(statement.Name, &ClassSignature{Name: statement.Name.Lexeme})
+			classes = append(classes, statement)
+		case TraitStmt:
+			traits = append(traits, statement)
+		}
+	}
+	for _, trait := range traits {
+		signature := &TraitSignature{map[string]*Signature{}}
+		for _, method := range trait.Methods {
+			signature.Methods[method.Name.Lexeme] = c.signature(method)
+		}
+		c.define(trait.Name, signature)
+	}
+	for _, class := range classes {
+		signature := c.lookup(class.Name.Lexeme).(*ClassSignature)
+		if class.Superclass != (VariableExpr{}) {
+			signature.Superclass, _ = c.lookup(class.Superclass.Name.Lexeme).(*ClassSignature)
+		}
+		signature.Methods = map[string]*Signature{}
+		for _, method := range class.Methods {
+			signature.Methods[method.Name.Lexeme] = c.signature(method)
+		}
+		for _, expr := range class.Traits {
+			trait, ok := c.lookup(expr.Name.Lexeme).(*TraitSignature)
+			if !ok {
+				continue
+			}
+			for name, method := range trait.Methods {
+				if _, found := signature.Methods[name]; !found {
+					signature.Methods[name] = method
+				}
+			}
+		}
+	}
+	for _, statement := range statements {
+		if function, ok := statement.(FunctionStmt); ok {
+			c.define(function.Name, c.signature(function))
+		}
+	}
+}
+
+func (c *Checker) VisitAssignExpr(expr AssignExpr) (any, error) {
+	value := c.check(expr.Value)
+	target := c.variableType(expr.Name.Lexeme)
+	if expr.Operator.TokenType != EqualToken {
+		operator := expr.Operator
+		operator.TokenType = compoundOperators[expr.Operator.TokenType]
+		value = c.binaryType(operator, target, value)
+	}
+	if !isAssignable(target, value) {
+		c.mismatch(expr.Name, "a value of type "+target.String()+" for '"+expr.Name.Lexeme+"'", value)
+	}
+	return value, nil
+}
+
+func (c *Checker) VisitBinaryExpr(expr BinaryExpr) (any, error) {
+	left := c.check(expr.Left)
+	right := c.check(expr.Right)
+	return c.binaryType(expr.Operator, left, right), nil
+}
+
+// Infers the type of a binary operation, reporting operands that would fail
+// at runtime.
+func (c *Checker) binaryType(operator Token, left, right StaticType) StaticType {
+	switch operator.TokenType {
+	case EqualEqualToken, BangEqualToken, IsToken:
+		return BoolType
+	case GreaterToken, GreaterEqualToken, LessToken, LessEqualToken:
+		c.checkNumberOperands(operator, left, right)
+		return BoolType
+	case PlusToken:
+		if left == AnyType || right == AnyType {
+			return AnyType
+		}
+		if (left == NumberType || left == StringType) && left == right {
+			return left
+		}
+		PrintDetailedError(operator, fmt.Sprintf("Operands of '+' must be two numbers or two strings but got %s and %s.", left, right))
+		return AnyType
+	}
+	c.checkNumberOperands(operator, left, right)
+	return NumberType
+}
+
+func (c *Checker) checkNumberOperands(operator Token, operands ...StaticType) {
+	for _, operand := range operands {
+		if !isAssignable(NumberType, operand) {
+			PrintDetailedError(operator, fmt.Sprintf("Operand of '%s' must be a number but got %s.", operator.Lexeme, operand))
+			return
+		}
+	}
+}
+
+func (c *Checker) VisitCallExpr(expr CallExpr) (any, error) {
+	callee := c.check(expr.Callee)
+	arguments := make([]StaticType, len(expr.Arguments))
+	for i, argument := range expr.Arguments {
+		arguments[i] = c.check(argument)
+	}
+	switch callee := callee.(type) {
+	case *Signature:
+		c.checkCall(expr, callee, arguments)
+		return callee.Returns, nil
+	case *ClassSignature:
+		if initializer, found := callee.FindMethod("init"); found {
+			c.checkCall(expr, initializer, arguments)
+		} else if len(arguments) > 0 {
+			PrintDetailedError(expr.Paren, arityMismatch(0, 0, len(arguments)))
+		}
+		return InstanceType{callee}, nil
+	case BasicType:
+		if callee != AnyType && callee != CallableType {
+			PrintDetailedError(expr.Paren, "Can only call functions and classes but got "+callee.String()+".")
+		}
+	case InstanceType:
+		if hook, found := callee.Class.FindMethod("__call"); found {
+			c.checkCall(expr, hook, arguments)
+			return hook.Returns, nil
+		}
+		PrintDetailedError(expr.Paren, "Can only call functions and classes but got "+callee.String()+".")
+	}
+	return AnyType, nil
+}
+
+// Checks the number and types of the arguments of a call to a known function.
+func (c *Checker) checkCall(expr CallExpr, signature *Signature, arguments []StaticType) {
+	if message := arityMismatch(signature.MinArity, signature.MaxArity, len(arguments)); message != "" {
+		PrintDetailedError(expr.Paren, message)
+		return
+	}
+	// Named arguments come last.
+	positional := len(arguments) - len(expr.Names)
+	for i, argument := range arguments {
+		param := i
+		if i >= positional {
+			param = -1
+			for j, name := range signature.Names {
+				if name == expr.Names[i-positional].Lexeme {
+					param = j
+				}
+			}
+		}
+		if param == -1 {
+			// Reported at runtime.
+			continue
+		}
+		if signature.MaxArity == -1 && param >= len(signature.Params)-1 {
+			if !isAssignable(signature.Rest, argument) {
+				c.mismatch(expr.Paren, "rest arguments of type "+signature.Rest.String()+" for '"+signature.Names[len(signature.Names)-1]+"'", argument)
+			}
+			continue
+		}
+		if !isAssignable(signature.Params[param], argument) {
+			c.mismatch(expr.Paren, "an argument of type "+signature.Params[param].String()+" for '"+signature.Names[param]+"'", argument)
+		}
+	}
+}
+
+func (c *Checker) VisitConditionalExpr(expr ConditionalExpr) (any, error) {
+	c.check(expr.Condition)
+	return c.join(c.check(expr.ThenBranch), c.check(expr.ElseBranch)), nil
+}
+
+func (c *Checker) VisitGetExpr(expr GetExpr) (any, error) {
+	if object, ok := c.check(expr.Object).(InstanceType); ok {
+		if method, found := object.Class.FindMethod(expr.Name.Lexeme); found {
+			return method, nil
+		}
+	}
+	// Fields aren't declared, so they can hold anything.
+	return AnyType, nil
+}
+
+func (c *Checker) VisitGroupingExpr(expr GroupingExpr) (any, error) {
+	return c.check(expr.Expression), nil
+}
+
+func (c *Checker) VisitLiteralExpr(expr LiteralExpr) (any, error) {
+	switch expr.Value.(type) {
+	case float64, int64, *big.Int:
+		return NumberType, nil
+	case string:
+		return StringType, nil
+	case bool:
+		return BoolType, nil
+	case nil:
+		return NilType, nil
+	}
+	return AnyType, nil
+}
+
+func (c *Checker) VisitLogicalExpr(expr LogicalExpr) (any, error) {
+	left := c.check(expr.Left)
+	right := c.check(expr.Right)
+	if expr.Operator.TokenType == QuestionQuestionToken && left == NilType {
+		return right, nil
+	}
+	return c.join(left, right), nil
+}
+
+func (c *Checker) VisitSetExpr(expr SetExpr) (any, error) {
+	c.check(expr.Object)
+	return c.check(expr.Value), nil
+}
+
+func (c *Checker) VisitSuperExpr(expr SuperExpr) (any, error) {
+	if c.currentClass != nil && c.currentClass.Superclass != nil {
+		if method, found := c.currentClass.Superclass.FindMethod(expr.Method.Lexeme); found {
+			return method, nil
+		}
+	}
+	return AnyType, nil
+}
+
+// Promises aren't typed, so neither are the values they settle with.
+func (c *Checker) VisitAwaitExpr(expr AwaitExpr) (any, error) {
+	c.check(expr.Value)
+	return AnyType, nil
+}
+
+// The value of a yield expression is passed to next(), so it has no static
+// type.
+func (c *Checker) VisitYieldExpr(expr YieldExpr) (any, error) {
+	if expr.Value != nil {
+		c.check(expr.Value)
+	}
+	return AnyType, nil
+}
+
+func (c *Checker) VisitThisExpr(expr ThisExpr) (any, error) {
+	if c.currentClass == nil {
+		return AnyType, nil
+	}
+	return InstanceType{c.currentClass}, nil
+}
+
+func (c *Checker) VisitUnaryExpr(expr UnaryExpr) (any, error) {
+	right := c.check(expr.Right)
+	if expr.Operator.TokenType == BangToken {
+		return BoolType, nil
+	}
+	c.checkNumberOperands(expr.Operator, right)
+	return NumberType, nil
+}
+
+func (c *Checker) VisitVariableExpr(expr VariableExpr) (any, error) {
+	return c.lookup(expr.Name.Lexeme), nil
+}
+
+func (c *Checker) VisitBlockStmt(stmt BlockStmt) (any, error) {
+	c.beginScope()
+	c.checkAll(stmt.Statements)
+	c.endScope()
+	return nil, nil
+}
+
+func (c *Checker) VisitClassStmt(stmt ClassStmt) (any, error) {
+	class, ok := c.lookup(stmt.Name.Lexeme).(*ClassSignature)
+	if !ok {
+		// The name was redeclared after declareAll declared the class.
+		c.declareAll([]Stmt{stmt})
+		class = c.lookup(stmt.Name.Lexeme).(*ClassSignature)
+	}
+	enclosingClass := c.currentClass
+	c.currentClass = class
+	for _, method := range stmt.Methods {
+		c.checkFunction(method, class.Methods[method.Name.Lexeme])
+	}
+	c.currentClass = enclosingClass
+	return nil, nil
+}
+
+func (c *Checker) VisitTraitStmt(stmt TraitStmt) (any, error) {
+	trait, ok := c.lookup(stmt.Name.Lexeme).(*TraitSignature)
+	if !ok {
+		// The name was redeclared after declareAll declared the trait.
+		c.declareAll([]Stmt{stmt})
+		trait = c.lookup(stmt.Name.Lexeme).(*TraitSignature)
+	}
+	for _, method := range stmt.Methods {
+		c.checkFunction(method, trait.Methods[method.Name.Lexeme])
+	}
+	return nil, nil
+}
+
+func (c *Checker) VisitDestructureStmt(stmt DestructureStmt) (any, error) {
+	for _, value := range stmt.Values {
+		c.check(value)
+	}
+	for _, name := range stmt.Names {
+		c.define(name, AnyType)
+	}
+	return nil, nil
+}
+
+func (c *Checker) VisitExpressionStmt(stmt ExpressionStmt) (any, error) {
+	c.check(stmt.Expression)
+	return nil, nil
+}
+
+func (c *Checker) VisitFunctionStmt(stmt FunctionStmt) (any, error) {
+	signature, ok := c.lookup(stmt.Name.Lexeme).(*Signature)
+	if !ok {
+		signature = c.signature(stmt)
+		c.define(stmt.Name, signature)
+	}
+	c.checkFunction(stmt, signature)
+	return nil, nil
+}
+
+func (c *Checker) VisitIfStmt(stmt IfStmt) (any, error) {
+	c.check(stmt.Condition)
+	stmt.ThenBranch.AcceptStmt(c)
+	if stmt.ElseBranch != nil {
+		stmt.ElseBranch.AcceptStmt(c)
+	}
+	return nil, nil
+}
+
+func (c *Checker) VisitMultiAssignStmt(stmt MultiAssignStmt) (any, error) {
+	values := make([]StaticType, len(stmt.Values))
+	for i, value := range stmt.Values {
+		values[i] = c.check(value)
+	}
+	for i, target := range stmt.Targets {
+		c.check(target)
+		variable, ok := target.(VariableExpr)
+		if !ok || i >= len(values) {
+			continue
+		}
+		if typ := c.variableType(variable.Name.Lexeme); !isAssignable(typ, values[i]) {
+			c.mismatch(variable.Name, "a value of type "+typ.String()+" for '"+variable.Name.Lexeme+"'", values[i])
+		}
+	}
+	return nil, nil
+}
+
+func (c *Checker) VisitMatchStmt(stmt MatchStmt) (any, error) {
+	c.check(stmt.Subject)
+	for _, matchCase := range stmt.Cases {
+		c.beginScope()
+		for _, pattern := range matchCase.Patterns {
+			c.definePattern(pattern)
+		}
+		if matchCase.Guard != nil {
+			c.check(matchCase.Guard)
+		}
+		matchCase.Body.AcceptStmt(c)
+		c.endScope()
+	}
+	return nil, nil
+}
+
+func (c *Checker) definePattern(pattern Pattern) {
+	switch pattern := pattern.(type) {
+	case BindingPattern:
+		c.define(pattern.Name, AnyType)
+	case ClassPattern:
+		for _, field := range pattern.Fields {
+			c.definePattern(field.Pattern)
+		}
+	}
+}
+
+func (c *Checker) VisitPrintStmt(stmt PrintStmt) (any, error) {
+	c.check(stmt.Expression)
+	return nil, nil
+}
+
+func (c *Checker) VisitReturnStmt(stmt ReturnStmt) (any, error) {
+	value := StaticType(NilType)
+	if stmt.Value != nil {
+		value = c.check(stmt.Value)
+	}
+	if c.currentReturn != nil && !isAssignable(c.currentReturn, value) {
+		c.mismatch(stmt.Keyword, "a return value of type "+c.currentReturn.String(), value)
+	}
+	return nil, nil
+}
+
+func (c *Checker) VisitVarStmt(stmt VarStmt) (any, error) {
+	typ := c.resolveType(stmt.Type)
+	value := StaticType(NilType)
+	if stmt.Initializer != nil {
+		value = c.check(stmt.Initializer)
+		if !isAssignable(typ, value) {
+			c.mismatch(stmt.Name, "a value of type "+typ.String()+" for '"+stmt.Name.Lexeme+"'", value)
+		}
+	}
+	// Constants can't change, so their type can be inferred.
+	if stmt.Type == (Token{}) && stmt.Keyword.TokenType == ConstToken {
+		typ = value
+	}
+	c.define(stmt.Name, typ)
+	return nil, nil
+}
+
+func (c *Checker) VisitForInStmt(stmt ForInStmt) (any, error) {
+	c.check(stmt.Iterable)
+	c.beginScope()
+	c.define(stmt.Name, AnyType)
+	stmt.Body.AcceptStmt(c)
+	c.endScope()
+	return nil, nil
+}
+
+func (c *Checker) VisitWhileStmt(stmt WhileStmt) (any, error) {
+	c.check(stmt.Condition)
+	stmt.Body.AcceptStmt(c)
+	return nil, nil
+}
+
+// Builds the signature of a function declaration from its annotations.
+func (c *Checker) signature(function FunctionStmt) *Signature {
+	signature := &Signature{
+		MinArity: 0,
+		MaxArity: len(function.Params),
+		Returns:  c.resolveType(function.ReturnType),
+	}
+	for _, param := range function.Params {
+		typ := c.resolveType(param.Type)
+		if param.Rest {
+			// The annotation of a rest parameter is the type of its elements.
+			signature.Rest = typ
+			typ = ListType
+			signature.MaxArity = -1
+		} else if param.Default == nil {
+			signature.MinArity++
+		}
+		signature.Params = append(signature.Params, typ)
+		signature.Names = append(signature.Names, param.Name.Lexeme)
+	}
+	if function.Generator || function.Async {
+		// Calls return a generator or a promise, whose values aren't checked.
+		signature.Returns = AnyType
+	}
+	return signature
+}
+
+func (c *Checker) checkFunction(function FunctionStmt, signature *Signature) {
+	enclosingReturn := c.currentReturn
+	c.currentReturn = signature.Returns
+	if c.currentClass != nil && function.Name.Lexeme == "init" {
+		// Initializers always return the instance.
+		c.currentReturn = AnyType
+	}
+	c.beginScope()
+	for i, param := range function.Params {
+		if param.Default != nil {
+			if value := c.check(param.Default); !isAssignable(signature.Params[i], value) {
+				c.mismatch(param.Name, "a default of type "+signature.Params[i].String()+" for '"+param.Name.Lexeme+"'", value)
+			}
+		}
+		c.define(param.Name, signature.Params[i])
+	}
+	c.checkAll(function.Body)
+	c.endScope()
+	c.currentReturn = enclosingReturn
+}
+
+// Returns the type named by an annotation, or Any if there is none.
+func (c *Checker) resolveType(name Token) StaticType {
+	if name == (Token{}) {
+		return AnyType
+	}
+	switch typ := BasicType(name.Lexeme); typ {
+	case AnyType, NumberType, StringType, BoolType, NilType, ListType, CallableType:
+		return typ
+	}
+	if class, ok := c.lookup(name.Lexeme).(*ClassSignature); ok {
+		return InstanceType{class}
+	}
+	PrintDetailedError(name, "Unknown type '"+name.Lexeme+"'.")
+	return AnyType
+}
+
+// The type of a value that is one of a or b.
+func (c *Checker) join(a, b StaticType) StaticType {
+	if a == b {
+		return a
+	}
+	return AnyType
+}
+
+func (c *Checker) mismatch(token Token, expected string, got StaticType) {
+	PrintDetailedError(token, "Expect "+expected+" but got "+got.String()+".")
+}
+
+func (c *Checker) check(expr Expr) StaticType {
+	typ, _ := expr.AcceptExpr(c)
+	return typ.(StaticType)
+}
+
+func (c *Checker) beginScope() {
+	c.scopes = append(c.scopes, map[string]StaticType{})
+}
+
+func (c *Checker) endScope() {
+	c.scopes = c.scopes[:len(c.scopes)-1]
+}
+
+func (c *Checker) define(name Token, typ StaticType) {
+	c.scopes[len(c.scopes)-1][name.Lexeme] = typ
+}
+
+// Returns the type of a variable, or Any for unknown variables such as
+// natives.
+func (c *Checker) lookup(name string) StaticType {
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		if typ, found := c.scopes[i][name]; found {
+			return typ
+		}
+	}
+	return AnyType
+}
+
+// Like lookup, but functions and classes are Any because they don't restrict
+// what can be assigned to their name.
+func (c *Checker) variableType(name string) StaticType {
+	switch typ := c.lookup(name).(type) {
+	case *Signature, *ClassSignature, *TraitSignature:
+		return AnyType
+	default:
+		return typ
+	}
+}
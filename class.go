@@ -1,6 +1,10 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
 
 // Runtime representation of a glox class.
 type Class struct {
@@ -9,37 +13,107 @@ type Class struct {
 	// Optional superclass extended by the class.
 	Superclass *Class
 	// Methods available to the class.
-	Methods map[string]Function
+	Methods map[string]*Function
+	// Traits mixed into the class.
+	Traits []*Trait
+	// Whether this is a data class, whose instances are compared and printed
+	// by Fields.
+	Data   bool
+	Fields []string
+	// Names of the abstract methods that the class doesn't implement, sorted.
+	// Classes with abstract methods can't be instantiated.
+	Abstract []string
+	// Documentation from the class's doc comments.
+	Doc string
 }
 
 // Creates a new class.
-func NewClass(name string, superclass *Class, methods map[string]Function) *Class {
-	return &Class{name, superclass, methods}
+func NewClass(name string, superclass *Class, methods map[string]*Function) *Class {
+	class := &Class{Name: name, Superclass: superclass, Methods: methods}
+	seen := map[string]bool{}
+	for c := class; c != nil; c = c.Superclass {
+		for name := range c.Methods {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			if method, _ := class.FindMethod(name); method.declaration.Abstract {
+				class.Abstract = append(class.Abstract, name)
+			}
+		}
+	}
+	sort.Strings(class.Abstract)
+	return class
 }
 
-// Searches for a method in a class or its inheritance chain.
+// Searches for a public method in a class or its inheritance chain.
 // Returns the method and a boolean indicating if the method was found.
-func (c *Class) FindMethod(name string) (Function, bool) {
+func (c *Class) FindMethod(name string) (*Function, bool) {
 	if method, found := c.Methods[name]; found {
 		return method, true
 	}
 	if c.Superclass != nil {
 		return c.Superclass.FindMethod(name)
 	}
-	return Function{}, false
+	return nil, false
+}
+
+// Whether the class is other or inherits from it.
+func (c *Class) IsSubclassOf(other *Class) bool {
+	for class := c; class != nil; class = class.Superclass {
+		if class == other {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether the class or one of its superclasses mixes in trait.
+func (c *Class) HasTrait(trait *Trait) bool {
+	for class := c; class != nil; class = class.Superclass {
+		for _, t := range class.Traits {
+			if t == trait {
+				return true
+			}
+		}
+	}
+	return false
 }
 
-// Number of arguments used in the initializer, if present. Otherwise, it is 0.
-func (c *Class) Arity() int {
+// Minimum number of arguments used in the initializer, if present. Otherwise,
+// it is 0.
+func (c *Class) MinArity() int {
 	if initializer, found := c.FindMethod("init"); found {
-		return initializer.Arity()
+		return initializer.MinArity()
 	}
 	return 0
 }
 
+// Maximum number of arguments used in the initializer, if present. Otherwise,
+// it is 0.
+func (c *Class) MaxArity() int {
+	if initializer, found := c.FindMethod("init"); found {
+		return initializer.MaxArity()
+	}
+	return 0
+}
+
+// Names of the initializer's parameters, so that classes accept named
+// arguments.
+func (c *Class) ParameterNames() []string {
+	if initializer, found := c.FindMethod("init"); found {
+		return initializer.ParameterNames()
+	}
+	return nil
+}
+
 // Creates a new instance and runs the initializer, if an initializer exists.
 // Returns the new instance and an error (if any) from initialization.
 func (c *Class) Call(interpreter Interpreter, arguments []any) (any, error) {
+	if len(c.Abstract) > 0 {
+		return nil, NativeError{fmt.Sprintf("Can't instantiate abstract class %s. It doesn't implement '%s'.",
+			c.Name, strings.Join(c.Abstract, "', '"))}
+	}
 	instance := NewInstance(c)
 	if initializer, found := c.FindMethod("init"); found {
 		if _, err := initializer.Bind(instance).Call(interpreter, arguments); err != nil {
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Runtime representation of a callable running on its own goroutine, returned
+// by spawn.
+type Task struct {
+	name string
+	// Closed when the call returns.
+	finished chan struct{}
+	value    any
+	err      error
+	// Guards joined, which is set once something waits for the task.
+	mu     sync.Mutex
+	joined bool
+}
+
+// Calls function with arguments on a new goroutine.
+func NewTask(interpreter Interpreter, function Callable, arguments []any) *Task {
+	task := &Task{name: fmt.Sprint(function), finished: make(chan struct{})}
+	// The task doesn't run the body of the generator that spawned it.
+	interpreter.generator = nil
+	go func() {
+		defer close(task.finished)
+		task.value, task.err = function.Call(interpreter, arguments)
+		// Nothing may ever join the task, e.g. when the script is blocked
+		// waiting for a value that the task was meant to send, so its error
+		// is reported right away. A later join() still fails with it.
+		task.mu.Lock()
+		defer task.mu.Unlock()
+		if task.err != nil && !task.joined {
+			reportLoopError(task.err)
+		}
+	}()
+	return task
+}
+
+// Waits for the call to return, then returns its value or error.
+func (t *Task) Join() (any, error) {
+	t.mu.Lock()
+	t.joined = true
+	t.mu.Unlock()
+	<-t.finished
+	return t.value, t.err
+}
+
+// Returns one of the task's methods bound to the task.
+func (t *Task) Get(name Token) (any, error) {
+	switch name.Lexeme {
+	case "join":
+		return NewNativeFunction("join", 0, func(interpreter Interpreter, arguments []any) (any, error) {
+			return t.Join()
+		}), nil
+	}
+	return nil, LogAndReturnError(name, "Undefined property '"+name.Lexeme+"'.")
+}
+
+func (t *Task) String() string {
+	return "<task " + t.name + ">"
+}
+
+// Runtime representation of a glox channel, which passes values between
+// tasks.
+type Channel struct {
+	values chan any
+}
+
+func NewChannel(capacity int) *Channel {
+	return &Channel{make(chan any, capacity)}
+}
+
+// Sends value, waiting until it is received or buffered.
+func (c *Channel) Send(value any) (err error) {
+	// Sending on a closed channel panics.
+	defer func() {
+		if recover() != nil {
+			err = NativeError{"Can't send on a closed channel."}
+		}
+	}()
+	c.values <- value
+	return nil
+}
+
+// Waits for a value. Returns nil once the channel is closed and drained.
+func (c *Channel) Receive() any {
+	return <-c.values
+}
+
+func (c *Channel) Close() (err error) {
+	// Closing a closed channel panics.
+	defer func() {
+		if recover() != nil {
+			err = NativeError{"Channel is already closed."}
+		}
+	}()
+	close(c.values)
+	return nil
+}
+
+// Returns one of the channel's methods bound to the channel.
+func (c *Channel) Get(name Token) (any, error) {
+	switch name.Lexeme {
+	case "send":
+		return NewNativeFunction("send", 1, func(interpreter Interpreter, arguments []any) (any, error) {
+			return nil, c.Send(arguments[0])
+		}), nil
+	case "receive":
+		return NewNativeFunction("receive", 0, func(interpreter Interpreter, arguments []any) (any, error) {
+			return c.Receive(), nil
+		}), nil
+	case "close":
+		return NewNativeFunction("close", 0, func(interpreter Interpreter, arguments []any) (any, error) {
+			return nil, c.Close()
+		}), nil
+	}
+	return nil, LogAndReturnError(name, "Undefined property '"+name.Lexeme+"'.")
+}
+
+func (c *Channel) String() string {
+	return fmt.Sprintf("<channel %d/%d>", len(c.values), cap(c.values))
+}
+
+// Runtime representation of a wait group, which waits for a number of tasks
+// to be done.
+type WaitGroup struct {
+	group sync.WaitGroup
+}
+
+// Returns one of the wait group's methods bound to the wait group.
+func (w *WaitGroup) Get(name Token) (any, error) {
+	switch name.Lexeme {
+	case "add":
+		add := NewNativeFunction("add", 0, func(interpreter Interpreter, arguments []any) (any, error) {
+			delta := int64(1)
+			if len(arguments) > 0 {
+				var ok bool
+				if delta, ok = arguments[0].(int64); !ok {
+					return nil, NativeError{"add expects an integer."}
+				}
+			}
+			return nil, w.add(int(delta))
+		})
+		add.maxArity = 1
+		return add, nil
+	case "done":
+		return NewNativeFunction("done", 0, func(interpreter Interpreter, arguments []any) (any, error) {
+			return nil, w.add(-1)
+		}), nil
+	case "wait":
+		return NewNativeFunction("wait", 0, func(interpreter Interpreter, arguments []any) (any, error) {
+			w.group.Wait()
+			return nil, nil
+		}), nil
+	}
+	return nil, LogAndReturnError(name, "Undefined property '"+name.Lexeme+"'.")
+}
+
+func (w *WaitGroup) add(delta int) (err error) {
+	// A negative counter panics.
+	defer func() {
+		if recover() != nil {
+			err = NativeError{"Wait group counter can't be negative."}
+		}
+	}()
+	w.group.Add(delta)
+	return nil
+}
+
+func (w *WaitGroup) String() string {
+	return "<wait group>"
+}
+
+// Calls a function with the remaining arguments on a new goroutine, and
+// returns a task whose join() waits for the result.
+func nativeSpawn(interpreter Interpreter, arguments []any) (any, error) {
+	function, ok := arguments[0].(Callable)
+	if !ok {
+		return nil, NativeError{"spawn expects a function."}
+	}
+	arguments = arguments[1:]
+	if message := arityMismatch(function.MinArity(), function.MaxArity(), len(arguments)); message != "" {
+		return nil, NativeError{message}
+	}
+	return NewTask(interpreter, function, arguments), nil
+}
+
+// Creates a channel, unbuffered unless a capacity is given.
+func nativeChannel(interpreter Interpreter, arguments []any) (any, error) {
+	capacity := int64(0)
+	if len(arguments) > 0 {
+		var ok bool
+		if capacity, ok = arguments[0].(int64); !ok || capacity < 0 {
+			return nil, NativeError{"Channel expects a non-negative integer capacity."}
+		}
+	}
+	return NewChannel(int(capacity)), nil
+}
+
+func nativeWaitGroup(interpreter Interpreter, arguments []any) (any, error) {
+	return &WaitGroup{}, nil
+}
+
+// Waits until one of the channels has a value or is closed, and returns a list
+// of the channel and the value.
+func nativeSelect(interpreter Interpreter, arguments []any) (any, error) {
+	cases := make([]reflect.SelectCase, len(arguments))
+	for i, argument := range arguments {
+		channel, ok := argument.(*Channel)
+		if !ok {
+			return nil, NativeError{"select expects channels."}
+		}
+		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(channel.values)}
+	}
+	chosen, value, ok := reflect.Select(cases)
+	var received any
+	if ok {
+		received = value.Interface()
+	}
+	return NewList([]any{arguments[chosen], received}), nil
+}
@@ -1,10 +1,18 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"sync"
+)
 
+// Environments are shared by goroutines started with spawn, e.g. through
+// globals and closures, so every access to values and constants holds mu.
 type Environment struct {
 	enclosing *Environment
+	mu        sync.RWMutex
 	values    map[string]any
+	// Names of values that can't be reassigned.
+	constants map[string]bool
 }
 
 func NewEnvironment() *Environment {
@@ -14,6 +22,8 @@ func NewEnvironment() *Environment {
 }
 
 func (e *Environment) ToString() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
 	return fmt.Sprintf("Environment{\n\tenclosing=%+v,\nvalues=%v\n}", e.enclosing, e.values)
 }
 
@@ -25,17 +35,33 @@ func NewEnvironmentFromEnclosing(enclosing *Environment) *Environment {
 }
 
 func (e *Environment) Define(name string, value any) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// Redeclaring a name replaces the binding, including a constant one.
+	delete(e.constants, name)
 	e.values[name] = value
-	fmt.Printf("Environment.Define: %v -> %v -> %v\n\n", name, value, e)
+}
+
+// Defines a value that can't be reassigned.
+func (e *Environment) DefineConstant(name string, value any) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.values[name] = value
+	if e.constants == nil {
+		e.constants = map[string]bool{}
+	}
+	e.constants[name] = true
 }
 
 func (e *Environment) Get(name Token) (any, error) {
 	// println("xxx Get", name.String())
 	// fmt.Printf("xxx Environment.Get: %v -> %v -> %v\n\n", name, e.values, e)
 	// panic("xxx")
-	if _, found := e.values[name.Lexeme]; found {
-		fmt.Printf("Environment.Get: %v -> %v -> %v\n\n", name, e.values[name.Lexeme], e)
-		return e.values[name.Lexeme], nil
+	e.mu.RLock()
+	value, found := e.values[name.Lexeme]
+	e.mu.RUnlock()
+	if found {
+		return value, nil
 	}
 	if e.enclosing != nil {
 		return e.enclosing.Get(name)
@@ -45,7 +71,10 @@ func (e *Environment) Get(name Token) (any, error) {
 
 func (e *Environment) GetAt(distance int, name string) any {
 	// println("GetAt", name, "(", distance, ")")
-	if value, found := e.ancestor(distance).values[name]; found {
+	environment := e.ancestor(distance)
+	environment.mu.RLock()
+	defer environment.mu.RUnlock()
+	if value, found := environment.values[name]; found {
 		return value
 	} else {
 		// println(fmt.Sprintf("%v", e.ancestor(distance).values))
@@ -55,10 +84,14 @@ func (e *Environment) GetAt(distance int, name string) any {
 }
 
 func (e *Environment) AssignAt(distance int, name Token, value any) {
-	e.ancestor(distance).values[name.Lexeme] = value
+	environment := e.ancestor(distance)
+	environment.mu.Lock()
+	defer environment.mu.Unlock()
+	environment.values[name.Lexeme] = value
 	// println("AssignAt", name.String(), "(", distance, ")", value, "->", e.ancestor(distance).values)
 }
 
+// The enclosing environments never change, so walking them needs no lock.
 func (e *Environment) ancestor(distance int) *Environment {
 	// println("xxx looping over environments")
 	environment := e
@@ -71,11 +104,17 @@ func (e *Environment) ancestor(distance int) *Environment {
 
 func (e *Environment) Assign(name Token, value any) error {
 	// fmt.Println("xxx Assign called")
+	e.mu.Lock()
 	if _, found := e.values[name.Lexeme]; found {
+		defer e.mu.Unlock()
+		if e.constants[name.Lexeme] {
+			return RuntimeError{name, "Can't assign to constant '" + name.Lexeme + "'."}
+		}
 		e.values[name.Lexeme] = value
 		// fmt.Printf("xxx Environment.Assign: %v -> %v -> %v\n\n", name, value, e)
 		return nil
 	}
+	e.mu.Unlock()
 
 	if e.enclosing != nil {
 		return e.enclosing.Assign(name, value)
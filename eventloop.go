@@ -0,0 +1,415 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Single-threaded event loop that runs promise reactions and timer callbacks
+// after the script. Tasks may be added from goroutines started with spawn, but
+// they all run on the goroutine that calls Run.
+type EventLoop struct {
+	mu sync.Mutex
+	// Functions ready to run, in order. Promise reactions run before timers
+	// that are due.
+	tasks []func()
+	// Pending timers, ordered by when they are due.
+	timers timerQueue
+	// Timers that haven't been cleared or run for the last time, by id.
+	active map[int64]*timer
+	nextID int64
+	// Signalled when a task or timer is added while Run is waiting.
+	wake chan struct{}
+}
+
+type timer struct {
+	id       int64
+	due      time.Time
+	interval time.Duration
+	repeat   bool
+	callback func()
+	// Order in which timers were set, so that timers that are due at the same
+	// time run in that order.
+	sequence int64
+	cleared  bool
+}
+
+// Heap of timers ordered by when they are due.
+type timerQueue []*timer
+
+func (q timerQueue) Len() int { return len(q) }
+func (q timerQueue) Less(i, j int) bool {
+	if q[i].due.Equal(q[j].due) {
+		return q[i].sequence < q[j].sequence
+	}
+	return q[i].due.Before(q[j].due)
+}
+func (q timerQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *timerQueue) Push(x any)   { *q = append(*q, x.(*timer)) }
+func (q *timerQueue) Pop() any {
+	old := *q
+	timer := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return timer
+}
+
+func NewEventLoop() *EventLoop {
+	return &EventLoop{
+		active: map[int64]*timer{},
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Adds a function to run once the tasks before it have run.
+func (l *EventLoop) enqueue(task func()) {
+	l.mu.Lock()
+	l.tasks = append(l.tasks, task)
+	l.mu.Unlock()
+	l.signal()
+}
+
+func (l *EventLoop) signal() {
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Runs callback after delay, and then every delay if repeat is set. Returns
+// an id for clearTimer.
+func (l *EventLoop) setTimer(delay time.Duration, repeat bool, callback func()) int64 {
+	l.mu.Lock()
+	l.nextID++
+	timer := &timer{
+		id:       l.nextID,
+		due:      time.Now().Add(delay),
+		interval: delay,
+		repeat:   repeat,
+		callback: callback,
+		sequence: l.nextID,
+	}
+	l.active[timer.id] = timer
+	heap.Push(&l.timers, timer)
+	l.mu.Unlock()
+	l.signal()
+	return timer.id
+}
+
+// Stops a timer. Clearing a timer that has already run does nothing.
+func (l *EventLoop) clearTimer(id int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if timer, found := l.active[id]; found {
+		timer.cleared = true
+		delete(l.active, id)
+	}
+}
+
+// Runs tasks and timers until there are none left.
+func (l *EventLoop) Run() {
+	for {
+		l.mu.Lock()
+		if len(l.tasks) > 0 {
+			task := l.tasks[0]
+			l.tasks = l.tasks[1:]
+			l.mu.Unlock()
+			task()
+			continue
+		}
+		for len(l.timers) > 0 && l.timers[0].cleared {
+			heap.Pop(&l.timers)
+		}
+		if len(l.timers) == 0 {
+			l.mu.Unlock()
+			return
+		}
+		next := l.timers[0]
+		if wait := time.Until(next.due); wait > 0 {
+			l.mu.Unlock()
+			select {
+			case <-l.wake:
+			case <-time.After(wait):
+			}
+			continue
+		}
+		heap.Pop(&l.timers)
+		if next.repeat {
+			next.due = next.due.Add(next.interval)
+			heap.Push(&l.timers, next)
+		} else {
+			delete(l.active, next.id)
+		}
+		l.mu.Unlock()
+		next.callback()
+	}
+}
+
+// Reports an error that no script code handles, e.g. from a timer callback, an
+// uncaught rejection or a task that nobody joins. The loop keeps running.
+func reportLoopError(err error) {
+	switch err := err.(type) {
+	case RuntimeError:
+		PrintRuntimeError(err)
+	default:
+		println(fmt.Sprintf("Runtime error: %s\n", err.Error()))
+		hadRuntimeError.Store(true)
+	}
+}
+
+// Calls a callback from the event loop with arguments, checking its arity.
+func callFromLoop(interpreter Interpreter, function Callable, arguments []any) (any, error) {
+	if message := arityMismatch(function.MinArity(), function.MaxArity(), len(arguments)); message != "" {
+		return nil, NativeError{message}
+	}
+	return function.Call(interpreter, arguments)
+}
+
+type promiseState int
+
+const (
+	pending promiseState = iota
+	fulfilled
+	rejected
+)
+
+// Runtime representation of a promise, the eventual result of an async
+// function call, a timer or a Promise() settled by the script.
+type Promise struct {
+	loop  *EventLoop
+	mu    sync.Mutex
+	state promiseState
+	value any
+	err   error
+	// Called on the event loop once the promise settles.
+	reactions []func(value any, err error)
+	// Whether anything waits for the promise, so that a rejection is handled.
+	handled bool
+}
+
+func NewPromise(loop *EventLoop) *Promise {
+	return &Promise{loop: loop}
+}
+
+// Fulfills the promise with value, or rejects it with err if it isn't nil. A
+// promise settles only once. Fulfilling a promise with another promise settles
+// it like the other promise.
+func (p *Promise) settle(value any, err error) {
+	if other, ok := value.(*Promise); ok && err == nil {
+		other.onSettle(p.settle)
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.state != pending {
+		return
+	}
+	p.value, p.err = value, err
+	p.state = fulfilled
+	if err != nil {
+		p.state = rejected
+		// Checked after the reactions that are already queued have run, so
+		// that a rejection handled right after it happens isn't reported.
+		p.loop.enqueue(func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if !p.handled {
+				reportLoopError(err)
+			}
+		})
+	}
+	for _, reaction := range p.reactions {
+		reaction := reaction
+		p.loop.enqueue(func() { reaction(value, err) })
+	}
+	p.reactions = nil
+}
+
+// Calls reaction on the event loop once the promise settles.
+func (p *Promise) onSettle(reaction func(value any, err error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.handled = true
+	if p.state == pending {
+		p.reactions = append(p.reactions, reaction)
+		return
+	}
+	value, err := p.value, p.err
+	p.loop.enqueue(func() { reaction(value, err) })
+}
+
+// Returns a promise for the result of calling onFulfilled with the value of p,
+// or onRejected with the error message if p is rejected. Without onFulfilled,
+// the returned promise is fulfilled with the same value, and without
+// onRejected, it is rejected with the same error.
+func (p *Promise) then(interpreter Interpreter, onFulfilled Callable, onRejected Callable) *Promise {
+	next := NewPromise(p.loop)
+	p.onSettle(func(value any, err error) {
+		if err == nil && onFulfilled != nil {
+			next.settle(callFromLoop(interpreter, onFulfilled, []any{value}))
+		} else if err == nil {
+			next.settle(value, nil)
+		} else if onRejected != nil {
+			next.settle(callFromLoop(interpreter, onRejected, []any{errorMessage(err)}))
+		} else {
+			next.settle(nil, err)
+		}
+	})
+	return next
+}
+
+// Returns one of the promise's methods bound to the promise.
+func (p *Promise) Get(name Token) (any, error) {
+	switch name.Lexeme {
+	case "then":
+		then := NewNativeFunction("then", 1, func(interpreter Interpreter, arguments []any) (any, error) {
+			// Either function may be nil to pass the value or error on.
+			var callbacks [2]Callable
+			for i, argument := range arguments {
+				if argument == nil {
+					continue
+				}
+				callback, ok := argument.(Callable)
+				if !ok {
+					return nil, NativeError{"then expects a function or nil."}
+				}
+				callbacks[i] = callback
+			}
+			return p.then(interpreter, callbacks[0], callbacks[1]), nil
+		})
+		then.maxArity = 2
+		return then, nil
+	case "resolve":
+		return NewNativeFunction("resolve", 1, func(interpreter Interpreter, arguments []any) (any, error) {
+			p.settle(arguments[0], nil)
+			return nil, nil
+		}), nil
+	case "reject":
+		return NewNativeFunction("reject", 1, func(interpreter Interpreter, arguments []any) (any, error) {
+			p.settle(nil, NativeError{stringify(arguments[0])})
+			return nil, nil
+		}), nil
+	}
+	return nil, LogAndReturnError(name, "Undefined property '"+name.Lexeme+"'.")
+}
+
+func (p *Promise) String() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch p.state {
+	case fulfilled:
+		return "<promise " + stringify(p.value) + ">"
+	case rejected:
+		return "<promise rejected: " + errorMessage(p.err) + ">"
+	}
+	return "<promise pending>"
+}
+
+// The message of a runtime error, as seen by scripts.
+func errorMessage(err error) string {
+	switch err := err.(type) {
+	case RuntimeError:
+		return err.Message
+	case NativeError:
+		return err.Message
+	}
+	return err.Error()
+}
+
+// Starts a call to an async function whose arguments are bound in environment.
+// The body runs until its first await, and the rest of it runs on the event
+// loop. Returns a promise for the call's result.
+func NewAsyncCall(function *Function, interpreter Interpreter, environment *Environment) *Promise {
+	promise := NewPromise(interpreter.loop)
+	// Await expressions suspend the body like yield expressions do.
+	body := NewGenerator(function, interpreter, environment)
+	var step func(value any, err error)
+	step = func(value any, err error) {
+		result, err := body.resume(value, err)
+		if body.Done() {
+			promise.settle(result, err)
+			return
+		}
+		awaited, ok := result.(*Promise)
+		if !ok {
+			// Awaiting any other value still lets other tasks run first.
+			awaited = NewPromise(interpreter.loop)
+			awaited.settle(result, nil)
+		}
+		awaited.onSettle(step)
+	}
+	step(nil, nil)
+	return promise
+}
+
+// Returns a promise that is fulfilled with nil after a number of milliseconds.
+func nativeSleep(interpreter Interpreter, arguments []any) (any, error) {
+	delay, err := delayArgument("sleep", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+	promise := NewPromise(interpreter.loop)
+	interpreter.loop.setTimer(delay, false, func() { promise.settle(nil, nil) })
+	return promise, nil
+}
+
+func nativeSetTimeout(interpreter Interpreter, arguments []any) (any, error) {
+	return setTimer(interpreter, "setTimeout", false, arguments)
+}
+
+func nativeSetInterval(interpreter Interpreter, arguments []any) (any, error) {
+	return setTimer(interpreter, "setInterval", true, arguments)
+}
+
+// Calls a function with the remaining arguments after a number of
+// milliseconds, and then repeatedly if repeat is set. Returns the timer's id.
+func setTimer(interpreter Interpreter, native string, repeat bool, arguments []any) (any, error) {
+	function, ok := arguments[0].(Callable)
+	if !ok {
+		return nil, NativeError{native + " expects a function."}
+	}
+	delay, err := delayArgument(native, arguments[1])
+	if err != nil {
+		return nil, err
+	}
+	arguments = arguments[2:]
+	// Timer callbacks don't run in the body of the generator that set them.
+	interpreter.generator = nil
+	return interpreter.loop.setTimer(delay, repeat, func() {
+		if _, err := callFromLoop(interpreter, function, arguments); err != nil {
+			reportLoopError(err)
+		}
+	}), nil
+}
+
+// Clears a timer set by setTimeout or setInterval.
+func nativeClearTimer(interpreter Interpreter, arguments []any) (any, error) {
+	id, ok := arguments[0].(int64)
+	if !ok {
+		return nil, NativeError{"Expect a timer id."}
+	}
+	interpreter.loop.clearTimer(id)
+	return nil, nil
+}
+
+// Creates a pending promise that the script settles with resolve(value) or
+// reject(message).
+func nativePromise(interpreter Interpreter, arguments []any) (any, error) {
+	return NewPromise(interpreter.loop), nil
+}
+
+// Converts a number of milliseconds to a duration.
+func delayArgument(native string, value any) (time.Duration, error) {
+	switch value := value.(type) {
+	case int64:
+		if value >= 0 {
+			return time.Duration(value) * time.Millisecond, nil
+		}
+	case float64:
+		if value >= 0 {
+			return time.Duration(value * float64(time.Millisecond)), nil
+		}
+	}
+	return 0, NativeError{native + " expects a non-negative number of milliseconds."}
+}
@@ -2,8 +2,10 @@ package main
 
 type ExprVisitor interface {
 	VisitAssignExpr(expr AssignExpr) (any, error)
+	VisitAwaitExpr(expr AwaitExpr) (any, error)
 	VisitBinaryExpr(expr BinaryExpr) (any, error)
 	VisitCallExpr(expr CallExpr) (any, error)
+	VisitConditionalExpr(expr ConditionalExpr) (any, error)
 	VisitGetExpr(expr GetExpr) (any, error)
 	VisitGroupingExpr(expr GroupingExpr) (any, error)
 	VisitLiteralExpr(expr LiteralExpr) (any, error)
@@ -13,6 +15,7 @@ type ExprVisitor interface {
 	VisitThisExpr(expr ThisExpr) (any, error)
 	VisitUnaryExpr(expr UnaryExpr) (any, error)
 	VisitVariableExpr(expr VariableExpr) (any, error)
+	VisitYieldExpr(expr YieldExpr) (any, error)
 }
 
 type Expr interface {
@@ -20,14 +23,25 @@ type Expr interface {
 }
 
 type AssignExpr struct {
-	Name  Token
-	Value Expr
+	Name     Token
+	Operator Token
+	Value    Expr
+	Postfix  bool
 }
 
 func (expr AssignExpr) AcceptExpr(visitor ExprVisitor) (any, error) {
 	return visitor.VisitAssignExpr(expr)
 }
 
+type AwaitExpr struct {
+	Keyword Token
+	Value   Expr
+}
+
+func (expr AwaitExpr) AcceptExpr(visitor ExprVisitor) (any, error) {
+	return visitor.VisitAwaitExpr(expr)
+}
+
 type BinaryExpr struct {
 	Left     Expr
 	Operator Token
@@ -42,15 +56,27 @@ type CallExpr struct {
 	Callee    Expr
 	Paren     Token
 	Arguments []Expr
+	Names     []Token
 }
 
 func (expr CallExpr) AcceptExpr(visitor ExprVisitor) (any, error) {
 	return visitor.VisitCallExpr(expr)
 }
 
+type ConditionalExpr struct {
+	Condition  Expr
+	ThenBranch Expr
+	ElseBranch Expr
+}
+
+func (expr ConditionalExpr) AcceptExpr(visitor ExprVisitor) (any, error) {
+	return visitor.VisitConditionalExpr(expr)
+}
+
 type GetExpr struct {
-	Object Expr
-	Name   Token
+	Object   Expr
+	Name     Token
+	Optional bool
 }
 
 func (expr GetExpr) AcceptExpr(visitor ExprVisitor) (any, error) {
@@ -84,9 +110,11 @@ func (expr LogicalExpr) AcceptExpr(visitor ExprVisitor) (any, error) {
 }
 
 type SetExpr struct {
-	Object Expr
-	Name   Token
-	Value  Expr
+	Object   Expr
+	Name     Token
+	Operator Token
+	Value    Expr
+	Postfix  bool
 }
 
 func (expr SetExpr) AcceptExpr(visitor ExprVisitor) (any, error) {
@@ -126,3 +154,12 @@ type VariableExpr struct {
 func (expr VariableExpr) AcceptExpr(visitor ExprVisitor) (any, error) {
 	return visitor.VisitVariableExpr(expr)
 }
+
+type YieldExpr struct {
+	Keyword Token
+	Value   Expr
+}
+
+func (expr YieldExpr) AcceptExpr(visitor ExprVisitor) (any, error) {
+	return visitor.VisitYieldExpr(expr)
+}
@@ -5,10 +5,12 @@ type Function struct {
 	// Not completely confident about this.
 	closure       *Environment
 	isInitializer bool
+	// Class that declares the method, or nil for functions.
+	class *Class
 }
 
-func NewFunction(declaration FunctionStmt, closure *Environment, isInitializer bool) Function {
-	return Function{
+func NewFunction(declaration FunctionStmt, closure *Environment, isInitializer bool) *Function {
+	return &Function{
 		declaration:   declaration,
 		closure:       closure,
 		isInitializer: isInitializer,
@@ -16,39 +18,129 @@ func NewFunction(declaration FunctionStmt, closure *Environment, isInitializer b
 }
 
 // Manage name environments.
-func (f Function) Call(interpreter Interpreter, arguments []any) (any, error) {
+func (f *Function) Call(interpreter Interpreter, arguments []any) (any, error) {
+	// Tail calls made by the body are made here, one after another, rather
+	// than nested, so that tail recursion runs in constant Go stack space.
+	var site *TailCall
+	for {
+		value, tailCall, err := f.call(interpreter, arguments)
+		// Errors from the first call are reported at its call site by the
+		// caller, and errors from tail calls at theirs.
+		if nativeErr, ok := err.(NativeError); ok && site != nil {
+			return nil, LogAndReturnError(site.Paren, nativeErr.Message)
+		}
+		if tailCall == nil {
+			return value, err
+		}
+		site = tailCall
+		f, arguments = tailCall.Function, tailCall.Arguments
+	}
+}
+
+// Runs one call of the function. Returns the call in tail position that the
+// function returns instead of a value, if any.
+func (f *Function) call(interpreter Interpreter, arguments []any) (any, *TailCall, error) {
+	if f.declaration.Abstract {
+		return nil, nil, NativeError{"Can't call abstract method '" + f.declaration.Name.Lexeme + "'."}
+	}
 	// Use lexical scope at declaration.
 	environment := NewEnvironmentFromEnclosing(f.closure)
-	for i := 0; i < len(f.declaration.Params); i++ {
-		environment.Define(f.declaration.Params[i].Lexeme, arguments[i])
+	// Default values can refer to the parameters before them.
+	interpreter.environment = environment
+	for i, param := range f.declaration.Params {
+		if param.Rest {
+			var rest []any
+			if i < len(arguments) {
+				rest = append(rest, arguments[i:]...)
+			}
+			environment.Define(param.Name.Lexeme, NewList(rest))
+			break
+		}
+		var value any
+		if i < len(arguments) {
+			value = arguments[i]
+		}
+		if _, missing := value.(missingArgument); missing || i >= len(arguments) {
+			value = nil
+			if param.Default != nil {
+				var err error
+				if value, err = interpreter.evaluate(param.Default); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+		environment.Define(param.Name.Lexeme, value)
+	}
+	if f.declaration.Async {
+		// The body runs until its first await, and the rest of it on the
+		// event loop.
+		return NewAsyncCall(f, interpreter, environment), nil, nil
+	}
+	if f.declaration.Generator {
+		// The body runs when the generator's next() is called.
+		return NewGenerator(f, interpreter, environment), nil, nil
 	}
 	err := interpreter.executeBlock(f.declaration.Body, environment)
 	if err == nil {
-		return nil, nil
+		return nil, nil, nil
 	}
 	// `FunctionReturn` is technically not an error.
 	if fr, ok := err.(FunctionReturn); ok {
 		if f.isInitializer {
-			return f.closure.GetAt(0, "this"), nil
+			return f.closure.GetAt(0, "this"), nil, nil
 		}
-		return fr.Value, nil
+		return fr.Value, fr.TailCall, nil
 	}
 	if f.isInitializer {
-		return f.closure.GetAt(0, "this"), nil
+		return f.closure.GetAt(0, "this"), nil, nil
 	}
-	return nil, err
+	return nil, nil, err
 }
 
-func (f Function) Arity() int {
-	return len(f.declaration.Params)
+// Number of parameters without a default value.
+func (f *Function) MinArity() int {
+	arity := 0
+	for _, param := range f.declaration.Params {
+		if param.Default == nil && !param.Rest {
+			arity++
+		}
+	}
+	return arity
+}
+
+// Number of parameters, or -1 if there is a rest parameter.
+func (f *Function) MaxArity() int {
+	params := f.declaration.Params
+	if len(params) > 0 && params[len(params)-1].Rest {
+		return -1
+	}
+	return len(params)
 }
 
-func (f Function) Bind(instance *Instance) Function {
+func (f *Function) ParameterNames() []string {
+	var names []string
+	for _, param := range f.declaration.Params {
+		names = append(names, param.Name.Lexeme)
+	}
+	return names
+}
+
+func (f *Function) Bind(instance *Instance) *Function {
 	environment := NewEnvironmentFromEnclosing(f.closure)
 	environment.Define("this", instance)
-	return NewFunction(f.declaration, environment, f.isInitializer)
+	// Lets private member accesses find the class they belong to. The name
+	// can't clash with variables since identifiers don't start with '#'.
+	environment.Define("#class", f.class)
+	method := NewFunction(f.declaration, environment, f.isInitializer)
+	method.class = f.class
+	return method
+}
+
+// Documentation from the function's doc comments.
+func (f *Function) Doc() string {
+	return f.declaration.Doc
 }
 
-func (f Function) String() string {
+func (f *Function) String() string {
 	return "<fn " + f.declaration.Name.Lexeme + ">"
 }
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Runtime representation of a generator, returned by calling a function that
+// contains `yield`. The function's body runs on its own goroutine, which hands
+// control back and forth with the caller of next() over channels, so that the
+// body can suspend in the middle of the Go call stack that evaluates it.
+type Generator struct {
+	name  string
+	state *generatorState
+}
+
+// State shared with the goroutine running the body. It is separate from
+// Generator so that the goroutine doesn't keep the Generator reachable, which
+// lets the garbage collector notice when a suspended generator is abandoned.
+type generatorState struct {
+	function    *Function
+	interpreter Interpreter
+	environment *Environment
+	// Values passed to next(), which become the values of yield expressions,
+	// or errors that they fail with.
+	resume chan generatorResult
+	// Values yielded or returned by the body.
+	yield chan generatorResult
+	// Closed to make a suspended body unwind.
+	closed chan struct{}
+	// Guards the flags below, since generators can be shared by goroutines
+	// started with spawn.
+	mu sync.Mutex
+	// Whether the body has started, is running, and has finished or been
+	// closed.
+	started, running, done bool
+}
+
+type generatorResult struct {
+	value any
+	err   error
+	done  bool
+}
+
+// Error used to unwind the body of a closed generator. It never escapes the
+// generator's goroutine.
+type generatorClosed struct{}
+
+func (generatorClosed) Error() string {
+	return "generator closed"
+}
+
+// Creates a generator that runs the body of function in environment, which
+// holds its arguments.
+func NewGenerator(function *Function, interpreter Interpreter, environment *Environment) *Generator {
+	generator := &Generator{
+		name: function.declaration.Name.Lexeme,
+		state: &generatorState{
+			function:    function,
+			interpreter: interpreter,
+			environment: environment,
+			resume:      make(chan generatorResult),
+			yield:       make(chan generatorResult),
+			closed:      make(chan struct{}),
+		},
+	}
+	// Unwinds the goroutine of a generator that is suspended when nothing
+	// references it anymore.
+	runtime.SetFinalizer(generator, func(generator *Generator) {
+		generator.state.close()
+	})
+	return generator
+}
+
+// Resumes the body until it yields or returns, passing value to the yield
+// expression it is suspended at. Returns the yielded or returned value, or nil
+// once the generator is done.
+func (g *Generator) Next(value any) (any, error) {
+	return g.resume(value, nil)
+}
+
+// Resumes the body like Next, but makes the expression it is suspended at
+// fail with err if it isn't nil.
+func (g *Generator) resume(value any, err error) (any, error) {
+	state := g.state
+	state.mu.Lock()
+	if state.done {
+		state.mu.Unlock()
+		return nil, nil
+	}
+	if state.running {
+		state.mu.Unlock()
+		return nil, NativeError{"Generator '" + g.name + "' is already running."}
+	}
+	state.running = true
+	started := state.started
+	state.started = true
+	state.mu.Unlock()
+	if started {
+		state.resume <- generatorResult{value, err, false}
+	} else {
+		// The first value has no yield expression to go to.
+		go state.run()
+	}
+	result := <-state.yield
+	state.mu.Lock()
+	state.running = false
+	state.done = result.done
+	state.mu.Unlock()
+	return result.value, result.err
+}
+
+// Closes the generator, unwinding its body if it is suspended.
+func (g *Generator) Close() error {
+	g.state.mu.Lock()
+	running := g.state.running
+	g.state.mu.Unlock()
+	if running {
+		return NativeError{"Generator '" + g.name + "' is already running."}
+	}
+	g.state.close()
+	return nil
+}
+
+// Whether the generator has returned or been closed.
+func (g *Generator) Done() bool {
+	g.state.mu.Lock()
+	defer g.state.mu.Unlock()
+	return g.state.done
+}
+
+// Returns one of the generator's methods bound to the generator.
+func (g *Generator) Get(name Token) (any, error) {
+	switch name.Lexeme {
+	case "next":
+		next := NewNativeFunction("next", 0, func(interpreter Interpreter, arguments []any) (any, error) {
+			var value any
+			if len(arguments) > 0 {
+				value = arguments[0]
+			}
+			return g.Next(value)
+		})
+		next.maxArity = 1
+		return next, nil
+	case "done":
+		return NewNativeFunction("done", 0, func(interpreter Interpreter, arguments []any) (any, error) {
+			return g.Done(), nil
+		}), nil
+	case "close":
+		return NewNativeFunction("close", 0, func(interpreter Interpreter, arguments []any) (any, error) {
+			return nil, g.Close()
+		}), nil
+	}
+	return nil, LogAndReturnError(name, "Undefined property '"+name.Lexeme+"'.")
+}
+
+func (g *Generator) String() string {
+	return "<generator " + g.name + ">"
+}
+
+// Runs the body on the generator's goroutine.
+func (s *generatorState) run() {
+	interpreter := s.interpreter
+	interpreter.generator = s
+	err := interpreter.executeBlock(s.function.declaration.Body, s.environment)
+	if _, closed := err.(generatorClosed); closed {
+		return
+	}
+	var value any
+	if fr, ok := err.(FunctionReturn); ok {
+		value, err = fr.Value, nil
+	}
+	s.yield <- generatorResult{value, err, true}
+}
+
+// Suspends the body, handing value to the caller of next(). Called on the
+// generator's goroutine. Returns the value passed to the next call to next().
+func (s *generatorState) suspend(value any) (any, error) {
+	s.yield <- generatorResult{value, nil, false}
+	select {
+	case result := <-s.resume:
+		return result.value, result.err
+	case <-s.closed:
+		return nil, generatorClosed{}
+	}
+}
+
+func (s *generatorState) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done || s.running {
+		return
+	}
+	s.done = true
+	if s.started {
+		close(s.closed)
+	}
+}
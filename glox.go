@@ -2,8 +2,10 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
+	"sync/atomic"
 )
 
 // See https://man.freebsd.org/cgi/man.cgi?query=sysexits.
@@ -18,23 +20,35 @@ const (
 
 var intepreter = NewInterpreter()
 
-// Whether we've encountered an error.
-var hadError bool
+// Whether we've encountered an error. Atomic, since runtime errors in tasks
+// started with spawn are reported through report too.
+var hadError atomic.Bool
 
-// Whether we've encountered a runtime error.
-var hadRuntimeError bool
+// Whether we've encountered a runtime error. Atomic, since tasks started with
+// spawn report their own errors.
+var hadRuntimeError atomic.Bool
+
+// Whether line ends terminate statements. Set with -optional-semicolons.
+var optionalSemicolons bool
 
 func PrintDetailedError(token Token, message string) {
 	if token.TokenType == EOFToken {
 		report(token.Line, "at end", message)
+	} else if token.TokenType == SemicolonToken && token.Lexeme == "\n" {
+		report(token.Line, "at end of line", message)
 	} else {
 		report(token.Line, "at '"+token.Lexeme+"'", message)
 	}
 }
 
+// Reports a problem that doesn't stop the program from running.
+func PrintWarning(token Token, message string) {
+	println(fmt.Sprintf("[%d] Warning at '%s': %s", token.Line, token.Lexeme, message))
+}
+
 func PrintRuntimeError(err RuntimeError) {
 	println(fmt.Sprintf("[line %d] Runtime error: %s\n", err.Token.Line, err.Message))
-	hadRuntimeError = true
+	hadRuntimeError.Store(true)
 }
 
 func runFile(path string) {
@@ -43,7 +57,7 @@ func runFile(path string) {
 		fmt.Printf("Error reading file %q: %v\n", path, err)
 		os.Exit(SysexitsUsageSoftware)
 	}
-	if err := run(string(bytes)); err != nil {
+	if err := run(string(bytes), 1); err != nil {
 		switch typedErr := err.(type) {
 		case RuntimeError:
 			fmt.Printf("[line %d] Runtime error: %s\n", typedErr.Token.Line, typedErr.Message)
@@ -54,11 +68,44 @@ func runFile(path string) {
 			os.Exit(SysexitsUsageSoftware)
 		}
 	}
+	// Errors reported by the event loop, e.g. uncaught rejections, and by tasks
+	// that weren't joined.
+	if hadRuntimeError.Load() {
+		os.Exit(SysexitsUsageSoftware)
+	}
+}
+
+// Checks the type annotations of a script without running it. Exits with
+// SysexitsDataError if there are any errors.
+func checkFile(path string) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf("Error reading file %q: %v\n", path, err)
+		os.Exit(SysexitsUsageSoftware)
+	}
+	s := NewScanner(string(bytes))
+	s.optionalSemicolons = optionalSemicolons
+	parser := NewParser(s.ScanTokens())
+	parser.optionalSemicolons = optionalSemicolons
+	statements, err := parser.Parse()
+	if err == nil {
+		resolver := NewResolver(intepreter)
+		resolver.resolveAll(statements)
+	}
+	if !hadError.Load() {
+		NewChecker().checkAll(statements)
+	}
+	if hadError.Load() {
+		os.Exit(SysexitsDataError)
+	}
 }
 
 func runPrompt() {
 	reader := bufio.NewReader(os.Stdin)
 
+	// Each input continues the line numbering of the previous one so that
+	// tokens from different inputs never share a position.
+	line := 1
 	for {
 		print("> ")
 		bytes, err := reader.ReadBytes('\n')
@@ -70,14 +117,17 @@ func runPrompt() {
 			break
 		}
 		// Don't kill the session if the user makes an error.
-		_ = run(string(bytes))
+		_ = run(string(bytes), line)
+		line++
 	}
 }
 
-func run(source string) error {
-	s := NewScanner(source)
+func run(source string, line int) error {
+	s := NewScannerAtLine(source, line)
+	s.optionalSemicolons = optionalSemicolons
 	tokens := s.ScanTokens()
 	parser := NewParser(tokens)
+	parser.optionalSemicolons = optionalSemicolons
 	statements, err := parser.Parse()
 	if err != nil {
 		return err
@@ -87,12 +137,14 @@ func run(source string) error {
 	if _, err := resolver.resolveAll(statements); err != nil {
 		return err
 	}
-	if hadError {
+	if hadError.Load() {
 		return fmt.Errorf("Encountered an error")
 	}
 	if err := intepreter.Interpret(statements); err != nil {
 		return err
 	}
+	// Keep running until there are no promise reactions or timers left.
+	intepreter.loop.Run()
 	return nil
 }
 
@@ -107,16 +159,24 @@ func report(line int, where, message string) {
 		println(fmt.Sprintf("[%d] Error: %s", line, message))
 	}
 	println(fmt.Sprintf("[%d] Error %s: %s", line, where, message))
-	hadError = true
+	hadError.Store(true)
 }
 
 func main() {
-	args := os.Args
-	if len(args) > 2 {
-		println("Usage: glox [script]")
+	flag.BoolVar(&optionalSemicolons, "optional-semicolons", false, "end statements at line ends")
+	flag.Usage = func() {
+		println("Usage: glox [-optional-semicolons] [script]")
+		println("       glox [-optional-semicolons] check script")
+	}
+	flag.Parse()
+	args := flag.Args()
+	if len(args) == 2 && args[0] == "check" {
+		checkFile(args[1])
+	} else if len(args) > 1 {
+		flag.Usage()
 		os.Exit(SysexitsUsage)
-	} else if len(args) == 2 {
-		runFile(args[1])
+	} else if len(args) == 1 {
+		runFile(args[0])
 	} else {
 		runPrompt()
 	}
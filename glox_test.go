@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the expected output of script tests")
+
+// Set in the environment of a test binary that runs a script instead of the
+// tests, so that each script gets a fresh interpreter.
+const runMainEnv = "GLOX_TEST_RUN_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runMainEnv) != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// Runs each testdata/*/*.lox script and compares what it prints, and its exit
+// status if it isn't 0, with the .out file next to it. A first line like
+// `// args: check` passes arguments to glox before the script's path. Run
+// `go test -run TestScripts -update` to rewrite the .out files.
+func TestScripts(t *testing.T) {
+	scripts, err := filepath.Glob(filepath.Join("testdata", "*", "*.lox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, script := range scripts {
+		script := script
+		t.Run(strings.TrimSuffix(script[len("testdata/"):], ".lox"), func(t *testing.T) {
+			t.Parallel()
+			got := runScript(t, script)
+			golden := strings.TrimSuffix(script, ".lox") + ".out"
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("output of %s:\n%s\nwant:\n%s", script, got, want)
+			}
+		})
+	}
+}
+
+func runScript(t *testing.T, script string) []byte {
+	source, err := os.ReadFile(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var args []string
+	if first, _, _ := strings.Cut(string(source), "\n"); strings.HasPrefix(first, "// args:") {
+		args = strings.Fields(strings.TrimPrefix(first, "// args:"))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, os.Args[0], append(args, script)...)
+	cmd.Env = append(os.Environ(), runMainEnv+"=1")
+	output, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && ctx.Err() == nil {
+		output = append(output, fmt.Sprintf("[exit %d]\n", exitErr.ExitCode())...)
+	} else if err != nil {
+		t.Fatalf("running %s: %v\n%s", script, err, output)
+	}
+	return output
+}
@@ -1,33 +1,134 @@
 package main
 
+import (
+	"strings"
+	"sync"
+)
+
 type Instance struct {
 	Class *Class
+	// Held for every access to fields and privateFields, since instances can
+	// be shared by goroutines started with spawn.
+	mu sync.RWMutex
 	// Struct-internal.
 	fields map[string]any
+	// Private fields, e.g. `#count`, keyed by the class that declares them so
+	// that subclasses neither see nor clash with their superclass's.
+	privateFields map[privateKey]any
+}
+
+type privateKey struct {
+	class *Class
+	name  string
 }
 
 func NewInstance(class *Class) *Instance {
 	return &Instance{
-		Class:  class,
-		fields: map[string]any{},
+		Class:         class,
+		fields:        map[string]any{},
+		privateFields: map[privateKey]any{},
 	}
 }
 
 func (i *Instance) Get(name Token) (any, error) {
-	if object, found := i.fields[name.Lexeme]; found {
+	if object, found := i.lookup(name.Lexeme); found {
+		return object, nil
+	}
+	err := LogAndReturnError(name, "Undefined property '"+name.Lexeme+"'.")
+	return nil, err
+}
+
+// Returns the field or bound method called name, if there is one.
+func (i *Instance) lookup(name string) (any, bool) {
+	if object, found := i.field(name); found {
+		return object, true
+	}
+	if method, found := i.Class.FindMethod(name); found {
+		return method.Bind(i), true
+	}
+	return nil, false
+}
+
+func (i *Instance) Set(name Token, value any) {
+	i.setField(name.Lexeme, value)
+}
+
+// Returns the public field called name, if there is one.
+func (i *Instance) field(name string) (any, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	value, found := i.fields[name]
+	return value, found
+}
+
+func (i *Instance) setField(name string, value any) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.fields[name] = value
+}
+
+// Returns the names of the public fields, in no particular order.
+func (i *Instance) fieldNames() []string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	var names []string
+	for name := range i.fields {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Gets a private field or method declared by class.
+func (i *Instance) GetPrivate(class *Class, name Token) (any, error) {
+	i.mu.RLock()
+	object, found := i.privateFields[privateKey{class, name.Lexeme}]
+	i.mu.RUnlock()
+	if found {
 		return object, nil
 	}
-	if method, found := i.Class.FindMethod(name.Lexeme); found {
+	// Private methods aren't inherited, so only class is searched.
+	if method, found := class.Methods[name.Lexeme]; found {
 		return method.Bind(i), nil
 	}
 	err := LogAndReturnError(name, "Undefined property '"+name.Lexeme+"'.")
 	return nil, err
 }
 
-func (i *Instance) Set(name Token, value any) {
-	i.fields[name.Lexeme] = value
+// Sets a private field declared by class.
+func (i *Instance) SetPrivate(class *Class, name Token, value any) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.privateFields[privateKey{class, name.Lexeme}] = value
+}
+
+// Whether name is a private member name, e.g. `#count`.
+func isPrivate(name Token) bool {
+	return name.TokenType == PrivateIdentifierToken
 }
 
 func (i *Instance) String() string {
-	return i.Class.Name + " instance"
+	return i.string(nil)
+}
+
+// Stringifies the instance, printing values that are being printed further up
+// as `...`.
+func (i *Instance) string(printing map[any]bool) string {
+	if !i.Class.Data {
+		return i.Class.Name + " instance"
+	}
+	if printing[i] {
+		return i.Class.Name + "(...)"
+	}
+	if printing == nil {
+		printing = map[any]bool{}
+	}
+	printing[i] = true
+	defer delete(printing, i)
+	// Data classes print their fields, e.g. `Point(x=1, y=2)`.
+	var fields []string
+	for _, field := range i.Class.Fields {
+		value, _ := i.field(field)
+		fields = append(fields, field+"="+stringifyPrinting(value, printing))
+	}
+	return i.Class.Name + "(" + strings.Join(fields, ", ") + ")"
 }
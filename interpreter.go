@@ -2,14 +2,19 @@ package main
 
 import (
 	"fmt"
-	"reflect"
+	"math/big"
 	"strconv"
+	"sync"
 	"time"
 )
 
 type Clock struct{}
 
-func (Clock) Arity() int {
+func (Clock) MinArity() int {
+	return 0
+}
+
+func (Clock) MaxArity() int {
 	return 0
 }
 
@@ -21,24 +26,45 @@ func (Clock) String() string {
 	return "<native fn: clock>"
 }
 
+// A runtime value with properties, e.g. instances and lists.
+type PropertyGetter interface {
+	Get(name Token) (any, error)
+}
+
 type Interpreter struct {
 	environment *Environment
 	// TODO: Figure out if we need globals.
 	globals *Environment
 	// string is the ptr of Expr
 	locals map[Expr]int
+	// Keywords of the return statements whose calls are tail calls.
+	tailCalls map[Token]bool
+	// Guards locals and tailCalls, which the resolver writes in the REPL while
+	// goroutines started with spawn read them. A pointer, since interpreters
+	// are copied.
+	resolved *sync.RWMutex
+	// Generator whose body is being run, which yield expressions suspend.
+	generator *generatorState
+	// Runs promise reactions and timers after the script.
+	loop *EventLoop
 }
 
 func NewInterpreter() *Interpreter {
 	globals := NewEnvironment()
 	environment := globals
 	globals.Define("clock", Clock{})
+	for _, native := range natives {
+		globals.Define(native.name, native)
+	}
 	return &Interpreter{
 		environment: environment,
 		globals:     environment,
 		// Each expression node is its own object. No need for a nested
 		// tree.
-		locals: map[Expr]int{},
+		locals:    map[Expr]int{},
+		tailCalls: map[Token]bool{},
+		resolved:  &sync.RWMutex{},
+		loop:      NewEventLoop(),
 	}
 }
 
@@ -68,9 +94,34 @@ func (i Interpreter) execute(statement Stmt) (any, error) {
 // TODO: Figure out if this hash is the correct way.
 func (i Interpreter) Resolve(expr Expr, depth int) {
 	// fmt.Println("xxx Resolving->", expr, &expr, depth)
+	i.resolved.Lock()
+	defer i.resolved.Unlock()
 	i.locals[expr] = depth
 }
 
+// Marks the call returned by stmt as a tail call.
+func (i Interpreter) ResolveTailCall(stmt ReturnStmt) {
+	i.resolved.Lock()
+	defer i.resolved.Unlock()
+	i.tailCalls[stmt.Keyword] = true
+}
+
+// Returns the number of environments between the one expr is evaluated in and
+// the one its variable is defined in, unless the variable is global.
+func (i Interpreter) local(expr Expr) (int, bool) {
+	i.resolved.RLock()
+	defer i.resolved.RUnlock()
+	distance, found := i.locals[expr]
+	return distance, found
+}
+
+// Whether the call returned by stmt is a tail call.
+func (i Interpreter) isTailCall(stmt ReturnStmt) bool {
+	i.resolved.RLock()
+	defer i.resolved.RUnlock()
+	return i.tailCalls[stmt.Keyword]
+}
+
 func (i Interpreter) executeBlock(statements []Stmt, environment *Environment) error {
 	// TODO: Figure out how this assignment will work.
 	previous := i.environment
@@ -108,6 +159,19 @@ func (i Interpreter) VisitClassStmt(stmt ClassStmt) (any, error) {
 			return nil, err
 		}
 	}
+	var traits []*Trait
+	for _, expr := range stmt.Traits {
+		value, err := i.evaluate(expr)
+		if err != nil {
+			return nil, err
+		}
+		trait, ok := value.(*Trait)
+		if !ok {
+			err := LogAndReturnError(expr.Name, "Can only mix in traits.")
+			return nil, err
+		}
+		traits = append(traits, trait)
+	}
 
 	i.environment.Define(stmt.Name.Lexeme, nil)
 	if stmt.Superclass != (VariableExpr{}) {
@@ -115,18 +179,30 @@ func (i Interpreter) VisitClassStmt(stmt ClassStmt) (any, error) {
 		i.environment.Define("super", superclass)
 	}
 
-	methods := map[string]Function{}
+	methods := map[string]*Function{}
 	for _, method := range stmt.Methods {
 		isInitializer := method.Name.Lexeme == "init"
 		function := NewFunction(method, i.environment, isInitializer)
 		methods[method.Name.Lexeme] = function
 	}
+	if err := mixInTraits(stmt, superclass, traits, methods); err != nil {
+		return nil, err
+	}
 	var class *Class
 	if superclass == nil {
 		class = NewClass(stmt.Name.Lexeme, nil, methods)
 	} else {
 		class = NewClass(stmt.Name.Lexeme, superclass.(*Class), methods)
 	}
+	class.Doc = stmt.Doc
+	class.Traits = traits
+	class.Data = stmt.Data
+	for _, field := range stmt.Fields {
+		class.Fields = append(class.Fields, field.Name.Lexeme)
+	}
+	for _, method := range methods {
+		method.class = class
+	}
 	if superclass != nil {
 		i.environment = i.environment.enclosing
 	}
@@ -136,6 +212,56 @@ func (i Interpreter) VisitClassStmt(stmt ClassStmt) (any, error) {
 	return nil, nil
 }
 
+// Copies the methods of traits into the methods of the class declared by stmt.
+// Methods declared by the class itself take precedence. It is an error for two
+// traits to provide a method that the class doesn't declare. Abstract trait
+// methods only require a method, so they don't replace or conflict with
+// implementations from the superclass or other traits.
+func mixInTraits(stmt ClassStmt, superclass any, traits []*Trait, methods map[string]*Function) error {
+	// Trait that provided each copied method.
+	providers := map[string]*Trait{}
+	for i, trait := range traits {
+		for name, method := range trait.Methods {
+			if _, found := methods[name]; found && providers[name] == nil {
+				continue
+			}
+			if method.declaration.Abstract {
+				if _, found := methods[name]; found {
+					continue
+				}
+				if superclass, ok := superclass.(*Class); ok {
+					if inherited, found := superclass.FindMethod(name); found && !inherited.declaration.Abstract {
+						continue
+					}
+				}
+			} else if providers[name] != nil && methods[name].declaration.Abstract {
+				delete(providers, name)
+			}
+			if provider, found := providers[name]; found {
+				return LogAndReturnError(stmt.Traits[i].Name, fmt.Sprintf(
+					"Traits %s and %s both define '%s'. Class %s must override it.",
+					provider.Name, trait.Name, name, stmt.Name.Lexeme))
+			}
+			providers[name] = trait
+			// Copied so that each class can own the method.
+			methods[name] = NewFunction(method.declaration, method.closure, method.isInitializer)
+		}
+	}
+	return nil
+}
+
+func (i Interpreter) VisitTraitStmt(stmt TraitStmt) (any, error) {
+	methods := map[string]*Function{}
+	for _, method := range stmt.Methods {
+		isInitializer := method.Name.Lexeme == "init"
+		methods[method.Name.Lexeme] = NewFunction(method, i.environment, isInitializer)
+	}
+	trait := NewTrait(stmt.Name.Lexeme, methods)
+	trait.Doc = stmt.Doc
+	i.environment.Define(stmt.Name.Lexeme, trait)
+	return nil, nil
+}
+
 func (i Interpreter) VisitLiteralExpr(expr LiteralExpr) (any, error) {
 	return expr.Value, nil
 }
@@ -146,11 +272,16 @@ func (i Interpreter) VisitLogicalExpr(expr LogicalExpr) (any, error) {
 		return nil, err
 	}
 
-	if expr.Operator.TokenType == OrToken {
+	switch expr.Operator.TokenType {
+	case QuestionQuestionToken:
+		if left != nil {
+			return left, nil
+		}
+	case OrToken:
 		if isTruthy(left) {
 			return left, nil
 		}
-	} else {
+	default:
 		if !isTruthy(left) {
 			return left, nil
 		}
@@ -159,25 +290,104 @@ func (i Interpreter) VisitLogicalExpr(expr LogicalExpr) (any, error) {
 }
 
 func (i Interpreter) VisitSetExpr(expr SetExpr) (any, error) {
-	object, err := i.evaluate(expr.Object)
+	target, err := i.propertyTarget(expr.Object, expr.Name)
 	if err != nil {
 		return nil, err
 	}
-	instance, ok := object.(*Instance)
-	if !ok {
-		err := LogAndReturnError(expr.Name, "Only instances have fields.")
-		return nil, err
+	var current any
+	if expr.Operator.TokenType != EqualToken {
+		if current, err = i.getTarget(target); err != nil {
+			return nil, err
+		}
 	}
 	value, err := i.evaluate(expr.Value)
 	if err != nil {
 		return nil, err
 	}
-	instance.Set(expr.Name, value)
+	if value, err = applyAssignment(expr.Operator, current, value); err != nil {
+		return nil, err
+	}
+	if err := i.setTarget(target, value); err != nil {
+		return nil, err
+	}
+	if expr.Postfix {
+		return current, nil
+	}
 	return value, nil
 }
 
+// A property being assigned, e.g. by `object.name = value` or a multiple
+// assignment, with its object evaluated.
+type property struct {
+	instance *Instance
+	name     Token
+	// Class of the method that assigns a private member.
+	class *Class
+	// Whether the assignment goes through `this`, which bypasses __set.
+	this bool
+}
+
+func (i Interpreter) propertyTarget(object Expr, name Token) (property, error) {
+	if isPrivate(name) {
+		instance, class, err := i.privateAccess(object, name)
+		return property{instance, name, class, true}, err
+	}
+	value, err := i.evaluate(object)
+	if err != nil {
+		return property{}, err
+	}
+	instance, ok := value.(*Instance)
+	if !ok {
+		return property{}, LogAndReturnError(name, "Only instances have fields.")
+	}
+	_, this := object.(ThisExpr)
+	return property{instance, name, nil, this}, nil
+}
+
+// Returns the current value of an assigned property, e.g. for `+=`.
+func (i Interpreter) getTarget(target property) (any, error) {
+	if target.class != nil {
+		return target.instance.GetPrivate(target.class, target.name)
+	}
+	return i.getProperty(target.instance, target.name)
+}
+
+func (i Interpreter) setTarget(target property, value any) error {
+	if target.class != nil {
+		target.instance.SetPrivate(target.class, target.name, value)
+		return nil
+	}
+	// Sets through `this` bypass __set, so that the class's own methods,
+	// including __set itself, can store fields.
+	hook, found := target.instance.Class.FindMethod("__set")
+	if found && !target.this {
+		_, err := i.callHook(target.name, hook.Bind(target.instance), []any{target.name.Lexeme, value})
+		return err
+	}
+	target.instance.Set(target.name, value)
+	return nil
+}
+
+// Private members can only be accessed through `this` in the methods of the
+// class that declares them. Returns the instance and the class of the method.
+func (i Interpreter) privateAccess(object Expr, name Token) (*Instance, *Class, error) {
+	this, ok := object.(ThisExpr)
+	if !ok {
+		err := LogAndReturnError(name, "Private member '"+name.Lexeme+"' can only be accessed through 'this'.")
+		return nil, nil, err
+	}
+	distance, found := i.local(this)
+	if !found {
+		err := LogAndReturnError(name, "Can't access private member '"+name.Lexeme+"' outside of a class.")
+		return nil, nil, err
+	}
+	instance := i.environment.GetAt(distance, "this").(*Instance)
+	class := i.environment.GetAt(distance, "#class").(*Class)
+	return instance, class, nil
+}
+
 func (i Interpreter) VisitSuperExpr(expr SuperExpr) (any, error) {
-	distance, found := i.locals[expr]
+	distance, found := i.local(expr)
 	if !found {
 		return nil, fmt.Errorf("Expected %v to be found in locals\n", expr)
 	}
@@ -206,7 +416,12 @@ func (i Interpreter) VisitUnaryExpr(expr UnaryExpr) (any, error) {
 		if err := checkNumberOperand(expr.Operator, right); err != nil {
 			return nil, err
 		}
-		return -(right.(float64)), nil
+		return negateNumber(right), nil
+	case TildeToken:
+		if err := checkIntegerOperand(expr.Operator, right); err != nil {
+			return nil, err
+		}
+		return complementInteger(right), nil
 	case BangToken:
 		return !isTruthy(right), nil
 	}
@@ -223,107 +438,280 @@ func (i Interpreter) VisitBinaryExpr(expr BinaryExpr) (any, error) {
 	if err != nil {
 		return nil, err
 	}
+	return applyBinary(expr.Operator, left, right)
+}
 
-	switch expr.Operator.TokenType {
+// Implements `value is type`, which holds if value is an instance of the class
+// type or one of its subclasses, or of a class that mixes in the trait type.
+func isInstanceOf(operator Token, value, typ any) (any, error) {
+	instance, ok := value.(*Instance)
+	switch typ := typ.(type) {
+	case *Class:
+		return ok && instance.Class.IsSubclassOf(typ), nil
+	case *Trait:
+		return ok && instance.Class.HasTrait(typ), nil
+	}
+	return nil, RuntimeError{operator, "Right operand of 'is' must be a class or trait."}
+}
+
+// Applies a binary operator to two evaluated operands.
+func applyBinary(operator Token, left, right any) (any, error) {
+	switch operator.TokenType {
 	case GreaterToken:
-		if err := checkNumberOperands(expr.Operator, left, right); err != nil {
+		if err := checkNumberOperands(operator, left, right); err != nil {
 			return nil, err
 		}
-		return left.(float64) > right.(float64), nil
+		c, ordered := compareNumbers(left, right)
+		return ordered && c > 0, nil
 	case GreaterEqualToken:
-		if err := checkNumberOperands(expr.Operator, left, right); err != nil {
+		if err := checkNumberOperands(operator, left, right); err != nil {
 			return nil, err
 		}
-		return left.(float64) >= right.(float64), nil
+		c, ordered := compareNumbers(left, right)
+		return ordered && c >= 0, nil
 	case LessToken:
-		if err := checkNumberOperands(expr.Operator, left, right); err != nil {
+		if err := checkNumberOperands(operator, left, right); err != nil {
 			return nil, err
 		}
-		return left.(float64) < right.(float64), nil
+		c, ordered := compareNumbers(left, right)
+		return ordered && c < 0, nil
 	case LessEqualToken:
-		if err := checkNumberOperands(expr.Operator, left, right); err != nil {
+		if err := checkNumberOperands(operator, left, right); err != nil {
 			return nil, err
 		}
-		return left.(float64) <= right.(float64), nil
+		c, ordered := compareNumbers(left, right)
+		return ordered && c <= 0, nil
+	case IsToken:
+		return isInstanceOf(operator, left, right)
 	// NOTE: The two cases below differ from the Ch. 7 Java implementation.
 	// IMPORTANT: NaN != NaN according to the IEEE spec.
 	case BangEqualToken:
-		return !reflect.DeepEqual(left, right), nil
+		return !isEqual(left, right), nil
 	case EqualEqualToken:
-		// TODO: Figur out if equal is necessary.
-		return reflect.DeepEqual(left, right), nil
+		return isEqual(left, right), nil
 	case MinusToken:
-		if err := checkNumberOperands(expr.Operator, left, right); err != nil {
+		if err := checkNumberOperands(operator, left, right); err != nil {
 			return nil, err
 		}
-		return left.(float64) - right.(float64), nil
+		return subtractNumbers(left, right), nil
 	case PlusToken:
-		var leftKind, rightKind any
-		if left != nil {
-			leftKind = reflect.TypeOf(left).Kind()
-		}
-		if right != nil {
-			rightKind = reflect.TypeOf(right).Kind()
-		}
-		if leftKind == reflect.Float64 && rightKind == reflect.Float64 {
-			return left.(float64) + right.(float64), nil
+		if isNumber(left) && isNumber(right) {
+			return addNumbers(left, right), nil
 		}
-		if leftKind == reflect.String && rightKind == reflect.String {
-			return left.(string) + right.(string), nil
+		if left, ok := left.(string); ok {
+			if right, ok := right.(string); ok {
+				return left + right, nil
+			}
 		}
-		return nil, RuntimeError{expr.Operator, fmt.Sprintf("Operands (%v, %v) must be two numbers or two strings", left, right)}
+		return nil, RuntimeError{operator, fmt.Sprintf("Operands (%v, %v) must be two numbers or two strings", left, right)}
 	case SlashToken:
-		if err := checkNumberOperands(expr.Operator, left, right); err != nil {
+		if err := checkNumberOperands(operator, left, right); err != nil {
 			return nil, err
 		}
-		return left.(float64) / right.(float64), nil
+		return divideNumbers(operator, left, right)
 	case StarToken:
-		if err := checkNumberOperands(expr.Operator, left, right); err != nil {
+		if err := checkNumberOperands(operator, left, right); err != nil {
+			return nil, err
+		}
+		return multiplyNumbers(left, right), nil
+	case PercentToken:
+		if err := checkNumberOperands(operator, left, right); err != nil {
+			return nil, err
+		}
+		return remainderNumbers(operator, left, right)
+	case StarStarToken:
+		if err := checkNumberOperands(operator, left, right); err != nil {
+			return nil, err
+		}
+		return powerNumbers(operator, left, right)
+	case AmpersandToken, PipeToken, CaretToken:
+		if err := checkIntegerOperands(operator, left, right); err != nil {
+			return nil, err
+		}
+		return bitwiseIntegers(operator.TokenType, left, right), nil
+	case LessLessToken, GreaterGreaterToken:
+		if err := checkIntegerOperands(operator, left, right); err != nil {
 			return nil, err
 		}
-		return left.(float64) * right.(float64), nil
+		return shiftIntegers(operator, left, right)
 	}
 	// Unreachable.
 	return nil, nil
 }
 
 func (i Interpreter) VisitCallExpr(expr CallExpr) (any, error) {
-	callee, err := i.evaluate(expr.Callee)
+	function, arguments, err := i.prepareCall(expr)
 	if err != nil {
 		return nil, err
 	}
-	var arguments []any
-	for _, argument := range expr.Arguments {
-		value, err := i.evaluate(argument)
-		if err != nil {
-			return nil, err
+	return i.call(expr, function, arguments)
+}
+
+// Evaluates the callee and arguments of a call, and checks that they match.
+func (i Interpreter) prepareCall(expr CallExpr) (Callable, []any, error) {
+	callee, err := i.evaluateChain(expr.Callee)
+	if err != nil {
+		return nil, nil, err
+	}
+	arguments, err := i.evaluateAll(expr.Arguments)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Instances of classes with a __call method can be called like functions.
+	if instance, ok := callee.(*Instance); ok {
+		if hook, found := instance.Class.FindMethod("__call"); found {
+			callee = hook.Bind(instance)
 		}
-		arguments = append(arguments, value)
 	}
 	function, ok := callee.(Callable)
 	if !ok {
 		err := LogAndReturnError(expr.Paren, "Can only call functions and classes.")
-		return nil, err
+		return nil, nil, err
+	}
+	if len(expr.Names) > 0 {
+		if arguments, err = arrangeNamedArguments(expr, function, arguments); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := checkArity(expr.Paren, function, arguments); err != nil {
+		return nil, nil, err
+	}
+	return function, arguments, nil
+}
+
+func (i Interpreter) call(expr CallExpr, function Callable, arguments []any) (any, error) {
+	value, err := function.Call(i, arguments)
+	if nativeErr, ok := err.(NativeError); ok {
+		return nil, LogAndReturnError(expr.Paren, nativeErr.Message)
+	}
+	return value, err
+}
+
+// Moves named arguments into the positions of their parameters. Parameters
+// that are skipped get a missingArgument so that their default is used.
+func arrangeNamedArguments(expr CallExpr, function Callable, values []any) ([]any, error) {
+	named, ok := function.(NamedCallable)
+	if !ok {
+		return nil, LogAndReturnError(expr.Paren, fmt.Sprintf("%s doesn't accept named arguments.", function))
+	}
+	params := named.ParameterNames()
+	if function.MaxArity() == -1 {
+		// The rest parameter can't be passed by name.
+		params = params[:len(params)-1]
+	}
+	positional := len(values) - len(expr.Names)
+	arguments := append([]any{}, values[:positional]...)
+	for len(arguments) < len(params) {
+		arguments = append(arguments, missingArgument{})
+	}
+	for index, name := range expr.Names {
+		position := -1
+		for j, param := range params {
+			if param == name.Lexeme {
+				position = j
+			}
+		}
+		if position == -1 {
+			return nil, LogAndReturnError(name, fmt.Sprintf("%s has no parameter named '%s'.", function, name.Lexeme))
+		}
+		if _, missing := arguments[position].(missingArgument); !missing {
+			return nil, LogAndReturnError(name, fmt.Sprintf("Argument '%s' is passed more than once.", name.Lexeme))
+		}
+		arguments[position] = values[positional+index]
+	}
+	// Required parameters always come first.
+	for j := 0; j < function.MinArity(); j++ {
+		if _, missing := arguments[j].(missingArgument); missing {
+			return nil, LogAndReturnError(expr.Paren, fmt.Sprintf("Missing argument for parameter '%s'.", params[j]))
+		}
+	}
+	return arguments, nil
+}
+
+func checkArity(paren Token, function Callable, arguments []any) error {
+	message := arityMismatch(function.MinArity(), function.MaxArity(), len(arguments))
+	if message == "" {
+		return nil
+	}
+	return LogAndReturnError(paren, message)
+}
+
+// Describes why count arguments don't fit an arity of min to max (-1 for any
+// number). Returns "" if they fit.
+func arityMismatch(min, max, count int) string {
+	if count >= min && (max == -1 || count <= max) {
+		return ""
+	}
+	var expected string
+	switch {
+	case min == max:
+		expected = fmt.Sprintf("%d", min)
+	case max == -1:
+		expected = fmt.Sprintf("at least %d", min)
+	default:
+		expected = fmt.Sprintf("%d to %d", min, max)
 	}
-	if len(arguments) != function.Arity() {
-		err := LogAndReturnError(expr.Paren, fmt.Sprintf("Expected %d arguments but got %d.", +function.Arity(), len(arguments)))
+	return fmt.Sprintf("Expected %s arguments but got %d.", expected, count)
+}
+
+// Only the branch that is taken is evaluated.
+func (i Interpreter) VisitConditionalExpr(expr ConditionalExpr) (any, error) {
+	condition, err := i.evaluate(expr.Condition)
+	if err != nil {
 		return nil, err
 	}
-	return function.Call(i, arguments)
+	if isTruthy(condition) {
+		return i.evaluate(expr.ThenBranch)
+	}
+	return i.evaluate(expr.ElseBranch)
 }
 
 func (i Interpreter) VisitGetExpr(expr GetExpr) (any, error) {
-	object, err := i.evaluate(expr.Object)
+	if isPrivate(expr.Name) {
+		instance, class, err := i.privateAccess(expr.Object, expr.Name)
+		if err != nil {
+			return nil, err
+		}
+		return instance.GetPrivate(class, expr.Name)
+	}
+	object, err := i.evaluateChain(expr.Object)
 	if err != nil {
 		return nil, err
 	}
+	if object == nil && expr.Optional {
+		return nil, nilChain{}
+	}
 	if instance, ok := object.(*Instance); ok {
-		return instance.Get(expr.Name)
+		return i.getProperty(instance, expr.Name)
+	}
+	if object, ok := object.(PropertyGetter); ok {
+		return object.Get(expr.Name)
 	}
 	err = LogAndReturnError(expr.Name, "Only instances have properties.")
 	return nil, err
 }
 
+// Gets a field or method of an instance. If there is none, the class's __get
+// method is called with the property name instead, if it has one.
+func (i Interpreter) getProperty(instance *Instance, name Token) (any, error) {
+	if value, found := instance.lookup(name.Lexeme); found {
+		return value, nil
+	}
+	if hook, found := instance.Class.FindMethod("__get"); found {
+		return i.callHook(name, hook.Bind(instance), []any{name.Lexeme})
+	}
+	return instance.Get(name)
+}
+
+// Calls one of the __get, __set or __call methods that classes can define to
+// customize their instances. token is used to report errors.
+func (i Interpreter) callHook(token Token, hook *Function, arguments []any) (any, error) {
+	if err := checkArity(token, hook, arguments); err != nil {
+		return nil, err
+	}
+	return hook.Call(i, arguments)
+}
+
 func (i Interpreter) VisitGroupingExpr(expr GroupingExpr) (any, error) {
 	return i.evaluate(expr.Expression)
 }
@@ -334,7 +722,7 @@ func (i Interpreter) VisitVariableExpr(expr VariableExpr) (any, error) {
 
 func (i Interpreter) lookUpVariable(name Token, expr Expr) (any, error) {
 	// println("xxx", fmt.Sprintf("%v", &expr))
-	if distance, found := i.locals[expr]; found {
+	if distance, found := i.local(expr); found {
 		return i.environment.GetAt(distance, name.Lexeme), nil
 	} else {
 		return i.globals.Get(name)
@@ -342,6 +730,26 @@ func (i Interpreter) lookUpVariable(name Token, expr Expr) (any, error) {
 }
 
 func (i Interpreter) evaluate(expr Expr) (any, error) {
+	value, err := expr.AcceptExpr(i)
+	if _, ok := err.(nilChain); ok {
+		// The optional chain ends here, so it evaluates to nil.
+		return nil, nil
+	}
+	return value, err
+}
+
+// Returned when `?.` is applied to nil. It unwinds the rest of the chain of
+// property accesses and calls, e.g. all of `a?.b.c()` when a is nil, until
+// the chain is evaluated by evaluate.
+type nilChain struct{}
+
+func (nilChain) Error() string {
+	return "nil optional chain"
+}
+
+// Evaluates the receiver of a property access or call. Unlike evaluate, this
+// keeps an optional chain that short-circuited unwinding.
+func (i Interpreter) evaluateChain(expr Expr) (any, error) {
 	return expr.AcceptExpr(i)
 }
 
@@ -380,6 +788,83 @@ func (i Interpreter) VisitIfStmt(stmt IfStmt) (any, error) {
 	return nil, nil
 }
 
+// Runs the body of the first case with a pattern that matches the subject
+// and whose guard, if any, holds.
+func (i Interpreter) VisitMatchStmt(stmt MatchStmt) (any, error) {
+	subject, err := i.evaluate(stmt.Subject)
+	if err != nil {
+		return nil, err
+	}
+	for _, matchCase := range stmt.Cases {
+		// Variables bound by the patterns are scoped to the case.
+		environment := NewEnvironmentFromEnclosing(i.environment)
+		matched := matchCase.Patterns == nil
+		for _, pattern := range matchCase.Patterns {
+			if matched, err = i.matchPattern(pattern, subject, environment); err != nil {
+				return nil, err
+			}
+			if matched {
+				break
+			}
+		}
+		if matched && matchCase.Guard != nil {
+			scoped := i
+			scoped.environment = environment
+			guard, err := scoped.evaluate(matchCase.Guard)
+			if err != nil {
+				return nil, err
+			}
+			matched = isTruthy(guard)
+		}
+		if matched {
+			return nil, i.executeBlock([]Stmt{matchCase.Body}, environment)
+		}
+	}
+	return nil, nil
+}
+
+// Matches a value against a pattern, defining the variables the pattern binds
+// in environment.
+func (i Interpreter) matchPattern(pattern Pattern, value any, environment *Environment) (bool, error) {
+	switch pattern := pattern.(type) {
+	case LiteralPattern:
+		return isEqual(pattern.Value, value), nil
+	case BindingPattern:
+		if pattern.Name.Lexeme != "_" {
+			environment.Define(pattern.Name.Lexeme, value)
+		}
+		return true, nil
+	case ClassPattern:
+		// The class is resolved in the case's scope, like the bindings.
+		scoped := i
+		scoped.environment = environment
+		object, err := scoped.evaluate(pattern.Class)
+		if err != nil {
+			return false, err
+		}
+		class, ok := object.(*Class)
+		if !ok {
+			return false, RuntimeError{pattern.Class.Name, "Can only match instances of classes."}
+		}
+		instance, ok := value.(*Instance)
+		if !ok || !instance.Class.IsSubclassOf(class) {
+			return false, nil
+		}
+		for _, field := range pattern.Fields {
+			fieldValue, found := instance.field(field.Name.Lexeme)
+			if !found {
+				return false, nil
+			}
+			if matched, err := i.matchPattern(field.Pattern, fieldValue, environment); err != nil || !matched {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	// Unreachable.
+	return false, nil
+}
+
 func (i Interpreter) VisitPrintStmt(stmt PrintStmt) (any, error) {
 	value, err := i.evaluate(stmt.Expression)
 	if err != nil {
@@ -390,6 +875,9 @@ func (i Interpreter) VisitPrintStmt(stmt PrintStmt) (any, error) {
 }
 
 func (i Interpreter) VisitReturnStmt(stmt ReturnStmt) (any, error) {
+	if call, ok := stmt.Value.(CallExpr); ok && i.isTailCall(stmt) {
+		return nil, i.tailCall(call)
+	}
 	var value any
 	if stmt.Value != nil {
 		var err error
@@ -401,7 +889,27 @@ func (i Interpreter) VisitReturnStmt(stmt ReturnStmt) (any, error) {
 	// Go does not allow exceptions so return an error. Errors are propagated all
 	// the way up the stack(?).
 	// TODO: Verify if this is true.
-	return nil, FunctionReturn{value}
+	return nil, FunctionReturn{Value: value}
+}
+
+// Returns the result of a call in tail position. Calls to glox functions are
+// left to Function.Call, which makes them without nesting.
+func (i Interpreter) tailCall(expr CallExpr) error {
+	function, arguments, err := i.prepareCall(expr)
+	if _, ok := err.(nilChain); ok {
+		return FunctionReturn{}
+	}
+	if err != nil {
+		return err
+	}
+	if function, ok := function.(*Function); ok {
+		return FunctionReturn{TailCall: &TailCall{function, arguments, expr.Paren}}
+	}
+	value, err := i.call(expr, function, arguments)
+	if err != nil {
+		return err
+	}
+	return FunctionReturn{Value: value}
 }
 
 func (i Interpreter) VisitVarStmt(stmt VarStmt) (any, error) {
@@ -413,10 +921,86 @@ func (i Interpreter) VisitVarStmt(stmt VarStmt) (any, error) {
 			return nil, err
 		}
 	}
-	i.environment.Define(stmt.Name.Lexeme, value)
+	if stmt.Keyword.TokenType == ConstToken {
+		i.environment.DefineConstant(stmt.Name.Lexeme, value)
+	} else {
+		i.environment.Define(stmt.Name.Lexeme, value)
+	}
+	return nil, nil
+}
+
+func (i Interpreter) VisitDestructureStmt(stmt DestructureStmt) (any, error) {
+	values, err := i.evaluateAll(stmt.Values)
+	if err != nil {
+		return nil, err
+	}
+	if stmt.Fields != nil {
+		instance, ok := values[0].(*Instance)
+		if !ok {
+			return nil, RuntimeError{stmt.Keyword, "Can only destructure fields of instances."}
+		}
+		values = nil
+		for _, field := range stmt.Fields {
+			value, err := instance.Get(field)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+	}
+	for index, name := range stmt.Names {
+		var value any
+		if index < len(values) {
+			value = values[index]
+		}
+		if stmt.Keyword.TokenType == ConstToken {
+			i.environment.DefineConstant(name.Lexeme, value)
+		} else {
+			i.environment.Define(name.Lexeme, value)
+		}
+	}
+	return nil, nil
+}
+
+// Evaluates every value before assigning any target, so `a, b = b, a` swaps.
+func (i Interpreter) VisitMultiAssignStmt(stmt MultiAssignStmt) (any, error) {
+	values, err := i.evaluateAll(stmt.Values)
+	if err != nil {
+		return nil, err
+	}
+	for index, target := range stmt.Targets {
+		switch target := target.(type) {
+		case VariableExpr:
+			if distance, found := i.local(target); found {
+				i.environment.AssignAt(distance, target.Name, values[index])
+			} else if err := i.globals.Assign(target.Name, values[index]); err != nil {
+				return nil, err
+			}
+		case GetExpr:
+			property, err := i.propertyTarget(target.Object, target.Name)
+			if err != nil {
+				return nil, err
+			}
+			if err := i.setTarget(property, values[index]); err != nil {
+				return nil, err
+			}
+		}
+	}
 	return nil, nil
 }
 
+func (i Interpreter) evaluateAll(exprs []Expr) ([]any, error) {
+	var values []any
+	for _, expr := range exprs {
+		value, err := i.evaluate(expr)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
 // TODO: Figure out if we need to break early.
 func (i Interpreter) VisitWhileStmt(stmt WhileStmt) (any, error) {
 	for {
@@ -433,21 +1017,194 @@ func (i Interpreter) VisitWhileStmt(stmt WhileStmt) (any, error) {
 	}
 }
 
+// Runs the body once for each element of a list or each value of a generator,
+// with the element bound to a new variable. A generator is closed if the loop
+// ends before it is done.
+func (i Interpreter) VisitForInStmt(stmt ForInStmt) (any, error) {
+	iterable, err := i.evaluate(stmt.Iterable)
+	if err != nil {
+		return nil, err
+	}
+	run := func(element any) error {
+		environment := NewEnvironmentFromEnclosing(i.environment)
+		environment.Define(stmt.Name.Lexeme, element)
+		return i.executeBlock([]Stmt{stmt.Body}, environment)
+	}
+	switch iterable := iterable.(type) {
+	case *List:
+		// The length is checked on each iteration since the body may append.
+		for index := 0; ; index++ {
+			element, found := iterable.at(index)
+			if !found {
+				break
+			}
+			if err := run(element); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	case *Generator:
+		defer iterable.Close()
+		for {
+			value, err := iterable.Next(nil)
+			if nativeErr, ok := err.(NativeError); ok {
+				return nil, LogAndReturnError(stmt.Keyword, nativeErr.Message)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if iterable.Done() {
+				return nil, nil
+			}
+			if err := run(value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nil, LogAndReturnError(stmt.Keyword, "Can only iterate over lists and generators.")
+}
+
+// Suspends the body of an async function until the value, if it's a promise,
+// settles. The body is resumed by the event loop.
+func (i Interpreter) VisitAwaitExpr(expr AwaitExpr) (any, error) {
+	value, err := i.evaluate(expr.Value)
+	if err != nil {
+		return nil, err
+	}
+	value, err = i.generator.suspend(value)
+	if nativeErr, ok := err.(NativeError); ok {
+		// Rejections by scripts and natives have no token of their own.
+		return nil, LogAndReturnError(expr.Keyword, nativeErr.Message)
+	}
+	return value, err
+}
+
+func (i Interpreter) VisitYieldExpr(expr YieldExpr) (any, error) {
+	var value any
+	if expr.Value != nil {
+		var err error
+		if value, err = i.evaluate(expr.Value); err != nil {
+			return nil, err
+		}
+	}
+	return i.generator.suspend(value)
+}
+
 func (i Interpreter) VisitAssignExpr(expr AssignExpr) (any, error) {
 	// println("xxx VisitAssignExpr")
+	// The resolver records the depth of the assigned variable under its name.
+	target := VariableExpr{expr.Name}
+	var current any
+	if expr.Operator.TokenType != EqualToken {
+		var err error
+		if current, err = i.lookUpVariable(expr.Name, target); err != nil {
+			return nil, err
+		}
+	}
 	value, err := i.evaluate(expr.Value)
 	if err != nil {
 		return nil, err
 	}
-	if distance, found := i.locals[expr]; found {
+	if value, err = applyAssignment(expr.Operator, current, value); err != nil {
+		return nil, err
+	}
+	if distance, found := i.local(target); found {
 		i.environment.AssignAt(distance, expr.Name, value)
+	} else if err = i.globals.Assign(expr.Name, value); err != nil {
+		return nil, err
+	}
+	if expr.Postfix {
+		return current, nil
+	}
+	return value, nil
+}
+
+// Binary operators applied by compound assignments.
+var compoundOperators = map[TokenType]TokenType{
+	PlusEqualToken:  PlusToken,
+	MinusEqualToken: MinusToken,
+	StarEqualToken:  StarToken,
+	SlashEqualToken: SlashToken,
+	PlusPlusToken:   PlusToken,
+	MinusMinusToken: MinusToken,
+}
+
+// Computes the value stored by an assignment. `=` stores the value as is while
+// compound operators such as `+=` and `++` combine it with the current value.
+func applyAssignment(operator Token, current any, value any) (any, error) {
+	if operator.TokenType == EqualToken {
 		return value, nil
 	}
+	binary := operator
+	binary.TokenType = compoundOperators[operator.TokenType]
+	return applyBinary(binary, current, value)
+}
 
-	if err = i.globals.Assign(expr.Name, value); err != nil {
-		return nil, err
+// isEqual implements Lox equality. nil, booleans, numbers and strings are
+// compared by value, so NaN != NaN as per IEEE 754 and 1 == 1.0. Instances, classes and
+// functions are compared by identity: two distinct instances with the same
+// fields are not equal, and comparing closures never walks their environments.
+func isEqual(a, b any) bool {
+	return isEqualComparing(a, b, nil)
+}
+
+// Compares like isEqual. Pairs of data instances in comparing are already
+// being compared further up, so they are taken to be equal, which stops the
+// comparison of instances whose fields refer back to them.
+func isEqualComparing(a, b any, comparing map[[2]*Instance]bool) bool {
+	switch a := a.(type) {
+	case nil:
+		return b == nil
+	case bool:
+		b, ok := b.(bool)
+		return ok && a == b
+	case float64, int64, *big.Int:
+		if !isNumber(b) {
+			return false
+		}
+		c, ordered := compareNumbers(a, b)
+		return ordered && c == 0
+	case string:
+		b, ok := b.(string)
+		return ok && a == b
+	case *Instance:
+		b, ok := b.(*Instance)
+		if !ok {
+			return false
+		}
+		if a == b {
+			return true
+		}
+		// Instances of the same data class are equal if their fields are.
+		if a.Class != b.Class || !a.Class.Data {
+			return false
+		}
+		pair := [2]*Instance{a, b}
+		if comparing[pair] {
+			return true
+		}
+		if comparing == nil {
+			comparing = map[[2]*Instance]bool{}
+		}
+		comparing[pair] = true
+		defer delete(comparing, pair)
+		for _, field := range a.Class.Fields {
+			aValue, _ := a.field(field)
+			bValue, _ := b.field(field)
+			if !isEqualComparing(aValue, bValue, comparing) {
+				return false
+			}
+		}
+		return true
+	case *Class:
+		b, ok := b.(*Class)
+		return ok && a == b
+	case *Function:
+		b, ok := b.(*Function)
+		return ok && a == b
 	}
-	return value, nil
+	// Native functions are stateless values, so equal types are equal values.
+	return a == b
 }
 
 func isTruthy(value any) bool {
@@ -461,7 +1218,7 @@ func isTruthy(value any) bool {
 }
 
 func checkNumberOperand(operator Token, operand any) error {
-	if _, ok := operand.(float64); ok {
+	if isNumber(operand) {
 		return nil
 	}
 	// TODO: Need to figure out how to throw errors properly.
@@ -469,23 +1226,50 @@ func checkNumberOperand(operator Token, operand any) error {
 }
 
 func checkNumberOperands(operator Token, left any, right any) error {
-	leftKind := reflect.TypeOf(left).Kind()
-	rightKind := reflect.TypeOf(right).Kind()
-	if leftKind == reflect.Float64 && rightKind == reflect.Float64 {
+	if isNumber(left) && isNumber(right) {
 		return nil
 	}
 	return RuntimeError{operator, fmt.Sprintf("Operands (%v, %v) must be numbers but are (%T, %T).", left, right, left, right)}
 }
 
+func checkIntegerOperand(operator Token, operand any) error {
+	if isInteger(operand) {
+		return nil
+	}
+	return RuntimeError{operator, fmt.Sprintf("Operand '%v' must be an integer but is '%T'", operand, operand)}
+}
+
+func checkIntegerOperands(operator Token, left any, right any) error {
+	if isInteger(left) && isInteger(right) {
+		return nil
+	}
+	return RuntimeError{operator, fmt.Sprintf("Operands (%v, %v) must be integers but are (%T, %T).", left, right, left, right)}
+}
+
 // NOTE: Update this for any custom type that we want .
 func stringify(object any) string {
+	return stringifyPrinting(object, nil)
+}
+
+// Stringifies like stringify. Instances and lists in printing are already
+// being printed further up, and are abbreviated so that values that contain
+// themselves can be printed.
+func stringifyPrinting(object any, printing map[any]bool) string {
 	if object == nil {
 		return "nil"
 	}
 
 	switch object := object.(type) {
+	case *Instance:
+		return object.string(printing)
+	case *List:
+		return object.string(printing)
 	case float64:
 		return strconv.FormatFloat(object, 'f', -1, 64)
+	case int64:
+		return strconv.FormatInt(object, 10)
+	case *big.Int:
+		return object.String()
 	}
 	if stringer, ok := object.(fmt.Stringer); ok {
 		return stringer.String()
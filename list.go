@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Runtime representation of a glox list, e.g. the arguments collected by a
+// rest parameter.
+type List struct {
+	// Held for every access to Elements, since lists can be shared by
+	// goroutines started with spawn.
+	mu       sync.RWMutex
+	Elements []any
+}
+
+func NewList(elements []any) *List {
+	return &List{Elements: elements}
+}
+
+// Returns one of the list's methods bound to the list.
+func (l *List) Get(name Token) (any, error) {
+	switch name.Lexeme {
+	case "length":
+		return NewNativeFunction("length", 0, func(interpreter Interpreter, arguments []any) (any, error) {
+			l.mu.RLock()
+			defer l.mu.RUnlock()
+			return int64(len(l.Elements)), nil
+		}), nil
+	case "get":
+		return NewNativeFunction("get", 1, func(interpreter Interpreter, arguments []any) (any, error) {
+			l.mu.RLock()
+			defer l.mu.RUnlock()
+			index, err := l.index(name, arguments[0])
+			if err != nil {
+				return nil, err
+			}
+			return l.Elements[index], nil
+		}), nil
+	case "set":
+		return NewNativeFunction("set", 2, func(interpreter Interpreter, arguments []any) (any, error) {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			index, err := l.index(name, arguments[0])
+			if err != nil {
+				return nil, err
+			}
+			l.Elements[index] = arguments[1]
+			return arguments[1], nil
+		}), nil
+	case "append":
+		return NewNativeFunction("append", 1, func(interpreter Interpreter, arguments []any) (any, error) {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.Elements = append(l.Elements, arguments[0])
+			return nil, nil
+		}), nil
+	}
+	return nil, LogAndReturnError(name, "Undefined property '"+name.Lexeme+"'.")
+}
+
+// Returns the element at index, if the list is long enough.
+func (l *List) at(index int) (any, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if index >= len(l.Elements) {
+		return nil, false
+	}
+	return l.Elements[index], true
+}
+
+// Checks that value is a valid index into the list. The caller holds mu.
+func (l *List) index(name Token, value any) (int, error) {
+	index, ok := value.(int64)
+	if !ok || index < 0 || index >= int64(len(l.Elements)) {
+		return 0, RuntimeError{name, fmt.Sprintf("Index %s is out of bounds for list of length %d.", stringify(value), len(l.Elements))}
+	}
+	return int(index), nil
+}
+
+func (l *List) String() string {
+	return l.string(nil)
+}
+
+// Stringifies the list, printing values that are being printed further up as
+// `...`.
+func (l *List) string(printing map[any]bool) string {
+	if printing[l] {
+		return "[...]"
+	}
+	if printing == nil {
+		printing = map[any]bool{}
+	}
+	printing[l] = true
+	defer delete(printing, l)
+	// Copied so that the lock isn't held while stringifying the elements,
+	// which may include the list itself.
+	l.mu.RLock()
+	values := append([]any(nil), l.Elements...)
+	l.mu.RUnlock()
+	var elements []string
+	for _, element := range values {
+		elements = append(elements, stringifyPrinting(element, printing))
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
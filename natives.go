@@ -0,0 +1,195 @@
+package main
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// Native functions defined in the global scope.
+var natives = []*NativeFunction{
+	NewNativeFunction("doc", 1, nativeDoc),
+	NewNativeFunction("typeof", 1, nativeTypeof),
+	NewNativeFunction("classOf", 1, nativeClassOf),
+	NewNativeFunction("superclassOf", 1, nativeSuperclassOf),
+	NewNativeFunction("fields", 1, nativeFields),
+	NewNativeFunction("methods", 1, nativeMethods),
+	NewNativeFunction("hasField", 2, nativeHasField),
+	NewNativeFunction("getField", 2, nativeGetField),
+	NewNativeFunction("setField", 3, nativeSetField),
+	{"spawn", 1, -1, nativeSpawn},
+	{"Channel", 0, 1, nativeChannel},
+	{"select", 1, -1, nativeSelect},
+	NewNativeFunction("WaitGroup", 0, nativeWaitGroup),
+	NewNativeFunction("sleep", 1, nativeSleep),
+	{"setTimeout", 2, -1, nativeSetTimeout},
+	{"setInterval", 2, -1, nativeSetInterval},
+	NewNativeFunction("clearTimeout", 1, nativeClearTimer),
+	NewNativeFunction("clearInterval", 1, nativeClearTimer),
+	NewNativeFunction("Promise", 0, nativePromise),
+}
+
+// Returns the documentation of a function or class, or nil if it has none.
+func nativeDoc(interpreter Interpreter, arguments []any) (any, error) {
+	var doc string
+	switch value := arguments[0].(type) {
+	case *Function:
+		doc = value.Doc()
+	case *Class:
+		doc = value.Doc
+	case *Trait:
+		doc = value.Doc
+	}
+	if doc == "" {
+		return nil, nil
+	}
+	return doc, nil
+}
+
+// Returns the name of the kind of a value, e.g. "number" or "instance".
+func nativeTypeof(interpreter Interpreter, arguments []any) (any, error) {
+	switch arguments[0].(type) {
+	case nil:
+		return "nil", nil
+	case bool:
+		return "bool", nil
+	case float64, int64, *big.Int:
+		return "number", nil
+	case string:
+		return "string", nil
+	case *Class:
+		return "class", nil
+	case *Trait:
+		return "trait", nil
+	case *Instance:
+		return "instance", nil
+	case *List:
+		return "list", nil
+	case *Generator:
+		return "generator", nil
+	case *Task:
+		return "task", nil
+	case *Promise:
+		return "promise", nil
+	case *Channel:
+		return "channel", nil
+	case *WaitGroup:
+		return "wait group", nil
+	case Callable:
+		return "function", nil
+	}
+	return "object", nil
+}
+
+func nativeClassOf(interpreter Interpreter, arguments []any) (any, error) {
+	instance, err := instanceArgument("classOf", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+	return instance.Class, nil
+}
+
+// Returns the superclass of a class, or nil if it has none.
+func nativeSuperclassOf(interpreter Interpreter, arguments []any) (any, error) {
+	class, ok := arguments[0].(*Class)
+	if !ok {
+		return nil, NativeError{"superclassOf expects a class."}
+	}
+	if class.Superclass == nil {
+		return nil, nil
+	}
+	return class.Superclass, nil
+}
+
+// Returns the sorted names of the public fields of an instance.
+func nativeFields(interpreter Interpreter, arguments []any) (any, error) {
+	instance, err := instanceArgument("fields", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+	return sortedList(instance.fieldNames()), nil
+}
+
+// Returns the sorted names of the public methods of a class, including the
+// inherited ones.
+func nativeMethods(interpreter Interpreter, arguments []any) (any, error) {
+	class, ok := arguments[0].(*Class)
+	if !ok {
+		return nil, NativeError{"methods expects a class."}
+	}
+	seen := map[string]bool{}
+	var names []string
+	for ; class != nil; class = class.Superclass {
+		for name := range class.Methods {
+			if !seen[name] && !strings.HasPrefix(name, "#") {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return sortedList(names), nil
+}
+
+func nativeHasField(interpreter Interpreter, arguments []any) (any, error) {
+	instance, name, err := fieldArguments("hasField", arguments)
+	if err != nil {
+		return nil, err
+	}
+	_, found := instance.field(name)
+	return found, nil
+}
+
+func nativeGetField(interpreter Interpreter, arguments []any) (any, error) {
+	instance, name, err := fieldArguments("getField", arguments)
+	if err != nil {
+		return nil, err
+	}
+	value, found := instance.field(name)
+	if !found {
+		return nil, NativeError{"Undefined field '" + name + "'."}
+	}
+	return value, nil
+}
+
+func nativeSetField(interpreter Interpreter, arguments []any) (any, error) {
+	instance, name, err := fieldArguments("setField", arguments)
+	if err != nil {
+		return nil, err
+	}
+	instance.setField(name, arguments[2])
+	return arguments[2], nil
+}
+
+func instanceArgument(native string, value any) (*Instance, error) {
+	instance, ok := value.(*Instance)
+	if !ok {
+		return nil, NativeError{native + " expects an instance."}
+	}
+	return instance, nil
+}
+
+// Checks the instance and field name arguments of the field natives. Private
+// fields can't be accessed by name.
+func fieldArguments(native string, arguments []any) (*Instance, string, error) {
+	instance, err := instanceArgument(native, arguments[0])
+	if err != nil {
+		return nil, "", err
+	}
+	name, ok := arguments[1].(string)
+	if !ok {
+		return nil, "", NativeError{native + " expects a field name string."}
+	}
+	if strings.HasPrefix(name, "#") {
+		return nil, "", NativeError{"Can't access private member '" + name + "' by name."}
+	}
+	return instance, name, nil
+}
+
+func sortedList(names []string) *List {
+	sort.Strings(names)
+	elements := make([]any, len(names))
+	for i, name := range names {
+		elements[i] = name
+	}
+	return NewList(elements)
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// Lox has two kinds of numbers: floats (float64) and integers. Integers are
+// int64 and overflow into arbitrary precision (*big.Int). Results that fit in
+// an int64 again are always normalized back to int64, so a *big.Int value is
+// never in int64 range.
+//
+// Promotion rules for binary operators:
+//   - integer op integer yields an integer.
+//   - integer op float (or float op integer) yields a float.
+//
+// Division between two integers truncates towards zero and `%` takes the sign
+// of the dividend, matching Go.
+
+// isNumber reports whether value is a Lox number of either kind.
+func isNumber(value any) bool {
+	switch value.(type) {
+	case float64, int64, *big.Int:
+		return true
+	}
+	return false
+}
+
+// isInteger reports whether value is an integer Lox number.
+func isInteger(value any) bool {
+	switch value.(type) {
+	case int64, *big.Int:
+		return true
+	}
+	return false
+}
+
+// Converts a number to a float64. Big integers are rounded to the nearest
+// float64.
+func toFloat(value any) float64 {
+	switch value := value.(type) {
+	case float64:
+		return value
+	case int64:
+		return float64(value)
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(value).Float64()
+		return f
+	}
+	panic("toFloat called with a non-number")
+}
+
+// Converts an integer to a *big.Int. The result is always a fresh value that
+// the caller is free to modify.
+func toBigInt(value any) *big.Int {
+	switch value := value.(type) {
+	case int64:
+		return big.NewInt(value)
+	case *big.Int:
+		return new(big.Int).Set(value)
+	}
+	panic("toBigInt called with a non-integer")
+}
+
+// Returns b as an int64 if it fits, otherwise b itself.
+func normalizeInteger(b *big.Int) any {
+	if b.IsInt64() {
+		return b.Int64()
+	}
+	return b
+}
+
+// Parses an integer literal, overflowing into a *big.Int when necessary.
+func parseInteger(text string, base int) (any, bool) {
+	if n, err := strconv.ParseInt(text, base, 64); err == nil {
+		return n, true
+	}
+	b, ok := new(big.Int).SetString(text, base)
+	if !ok {
+		return nil, false
+	}
+	return normalizeInteger(b), true
+}
+
+func negateNumber(value any) any {
+	switch value := value.(type) {
+	case float64:
+		return -value
+	case int64:
+		if value == math.MinInt64 {
+			return new(big.Int).Neg(big.NewInt(value))
+		}
+		return -value
+	}
+	return normalizeInteger(new(big.Int).Neg(toBigInt(value)))
+}
+
+func addNumbers(left, right any) any {
+	if !isInteger(left) || !isInteger(right) {
+		return toFloat(left) + toFloat(right)
+	}
+	if l, ok := left.(int64); ok {
+		if r, ok := right.(int64); ok {
+			sum := l + r
+			// Overflow happened iff both operands have a sign different from the sum.
+			if (l^sum)&(r^sum) >= 0 {
+				return sum
+			}
+		}
+	}
+	return normalizeInteger(new(big.Int).Add(toBigInt(left), toBigInt(right)))
+}
+
+func subtractNumbers(left, right any) any {
+	if !isInteger(left) || !isInteger(right) {
+		return toFloat(left) - toFloat(right)
+	}
+	if l, ok := left.(int64); ok {
+		if r, ok := right.(int64); ok {
+			difference := l - r
+			if (l^r)&(l^difference) >= 0 {
+				return difference
+			}
+		}
+	}
+	return normalizeInteger(new(big.Int).Sub(toBigInt(left), toBigInt(right)))
+}
+
+func multiplyNumbers(left, right any) any {
+	if !isInteger(left) || !isInteger(right) {
+		return toFloat(left) * toFloat(right)
+	}
+	if l, ok := left.(int64); ok {
+		if r, ok := right.(int64); ok {
+			product := l * r
+			if l == 0 || (product/l == r && !(l == -1 && r == math.MinInt64)) {
+				return product
+			}
+		}
+	}
+	return normalizeInteger(new(big.Int).Mul(toBigInt(left), toBigInt(right)))
+}
+
+func divideNumbers(operator Token, left, right any) (any, error) {
+	if !isInteger(left) || !isInteger(right) {
+		return toFloat(left) / toFloat(right), nil
+	}
+	if isZeroInteger(right) {
+		return nil, RuntimeError{operator, "Division by zero."}
+	}
+	if l, ok := left.(int64); ok {
+		if r, ok := right.(int64); ok && !(l == math.MinInt64 && r == -1) {
+			return l / r, nil
+		}
+	}
+	return normalizeInteger(new(big.Int).Quo(toBigInt(left), toBigInt(right))), nil
+}
+
+func remainderNumbers(operator Token, left, right any) (any, error) {
+	if !isInteger(left) || !isInteger(right) {
+		return math.Mod(toFloat(left), toFloat(right)), nil
+	}
+	if isZeroInteger(right) {
+		return nil, RuntimeError{operator, "Division by zero."}
+	}
+	if l, ok := left.(int64); ok {
+		if r, ok := right.(int64); ok {
+			if r == -1 {
+				// Avoids overflowing on math.MinInt64 % -1.
+				return int64(0), nil
+			}
+			return l % r, nil
+		}
+	}
+	return normalizeInteger(new(big.Int).Rem(toBigInt(left), toBigInt(right))), nil
+}
+
+func isZeroInteger(value any) bool {
+	n, ok := value.(int64)
+	// Big integers are never in int64 range, so they can't be zero.
+	return ok && n == 0
+}
+
+// compareNumbers returns -1, 0 or 1 depending on whether left is less than,
+// equal to or greater than right. The boolean is false if the numbers are
+// unordered, which happens iff either of them is NaN.
+func compareNumbers(left, right any) (int, bool) {
+	if l, ok := left.(float64); ok {
+		if r, ok := right.(float64); ok {
+			switch {
+			case l < r:
+				return -1, true
+			case l > r:
+				return 1, true
+			case l == r:
+				return 0, true
+			}
+			return 0, false
+		}
+	}
+	if l, ok := left.(int64); ok {
+		if r, ok := right.(int64); ok {
+			switch {
+			case l < r:
+				return -1, true
+			case l > r:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	if isInteger(left) && isInteger(right) {
+		return toBigInt(left).Cmp(toBigInt(right)), true
+	}
+	// Mixed kinds are compared exactly instead of rounding the integer.
+	l, ok := toBigFloat(left)
+	if !ok {
+		return 0, false
+	}
+	r, ok := toBigFloat(right)
+	if !ok {
+		return 0, false
+	}
+	return l.Cmp(r), true
+}
+
+// Converts a number to a *big.Float without losing precision. Returns false
+// for NaN, which has no *big.Float representation.
+func toBigFloat(value any) (*big.Float, bool) {
+	switch value := value.(type) {
+	case float64:
+		if math.IsNaN(value) {
+			return nil, false
+		}
+		return new(big.Float).SetFloat64(value), true
+	case int64:
+		return new(big.Float).SetInt64(value), true
+	case *big.Int:
+		return new(big.Float).SetInt(value), true
+	}
+	panic("toBigFloat called with a non-number")
+}
+
+// Integers that `<<` and `**` would make longer than this many bits are a
+// runtime error rather than an attempt to allocate them.
+const maxIntegerBits = 1 << 24
+
+// Raises left to the power of right. An integer raised to a non-negative
+// integer is exact; any other combination yields a float.
+func powerNumbers(operator Token, left, right any) (any, error) {
+	if !isInteger(left) || !isInteger(right) || toBigInt(right).Sign() < 0 {
+		return math.Pow(toFloat(left), toFloat(right)), nil
+	}
+	base, exponent := toBigInt(left), toBigInt(right)
+	// Only 0, 1 and -1 stay small for any exponent.
+	if bits := int64(new(big.Int).Abs(base).BitLen()); bits > 1 {
+		if !exponent.IsInt64() || exponent.Int64() > maxIntegerBits/(bits-1) {
+			return nil, RuntimeError{operator, "Result of '**' is too large."}
+		}
+	}
+	return normalizeInteger(new(big.Int).Exp(base, exponent, nil)), nil
+}
+
+// Applies `&`, `|` or `^` to two integers.
+func bitwiseIntegers(operator TokenType, left, right any) any {
+	if l, ok := left.(int64); ok {
+		if r, ok := right.(int64); ok {
+			switch operator {
+			case AmpersandToken:
+				return l & r
+			case PipeToken:
+				return l | r
+			case CaretToken:
+				return l ^ r
+			}
+		}
+	}
+	result := new(big.Int)
+	switch operator {
+	case AmpersandToken:
+		result.And(toBigInt(left), toBigInt(right))
+	case PipeToken:
+		result.Or(toBigInt(left), toBigInt(right))
+	case CaretToken:
+		result.Xor(toBigInt(left), toBigInt(right))
+	}
+	return normalizeInteger(result)
+}
+
+// Returns the bitwise complement of an integer, i.e. -x - 1.
+func complementInteger(value any) any {
+	if n, ok := value.(int64); ok {
+		return ^n
+	}
+	return normalizeInteger(new(big.Int).Not(toBigInt(value)))
+}
+
+// Applies `<<` or `>>` to two integers. Right shifts are arithmetic, so they
+// preserve the sign of negative numbers.
+func shiftIntegers(operator Token, left, right any) (any, error) {
+	count, ok := right.(int64)
+	if !ok || count < 0 {
+		return nil, RuntimeError{operator, "Shift count must be a non-negative integer."}
+	}
+	if operator.TokenType == GreaterGreaterToken {
+		if l, ok := left.(int64); ok {
+			if count > 63 {
+				count = 63
+			}
+			return l >> count, nil
+		}
+		return normalizeInteger(new(big.Int).Rsh(toBigInt(left), uint(count))), nil
+	}
+	if l, ok := left.(int64); ok && count < 63 {
+		if shifted := l << count; shifted>>count == l {
+			return shifted, nil
+		}
+	}
+	if bits := int64(toBigInt(left).BitLen()); bits > 0 && count > maxIntegerBits-bits {
+		return nil, RuntimeError{operator, "Result of '<<' is too large."}
+	}
+	return normalizeInteger(new(big.Int).Lsh(toBigInt(left), uint(count))), nil
+}
@@ -0,0 +1,15 @@
+package main
+
+// A parameter of a function declaration.
+type Param struct {
+	Name Token
+	// Optional type annotation, e.g. `w: Number`. It is the zero Token when
+	// the parameter is unannotated.
+	Type Token
+	// Optional value used when no argument is passed, e.g. `b = 2`. It is
+	// evaluated on each call, after the preceding parameters are bound.
+	Default Expr
+	// Whether this is a trailing rest parameter, e.g. `...args`, which
+	// collects the remaining arguments into a list.
+	Rest bool
+}
@@ -13,6 +13,14 @@ type Parser struct {
 	tokens []Token
 	// Points to the next token to be parsed.
 	current int
+	// Whether statements may end without a semicolon before a `}`, in
+	// addition to the line ends the scanner terminates.
+	optionalSemicolons bool
+	// Number of tokens created by syntheticToken.
+	synthesized int
+	// Whether the function being parsed contains `yield`, which makes it a
+	// generator.
+	yielded bool
 }
 
 // Use a pointer receiver to ensure that methods can modify the values.
@@ -26,7 +34,7 @@ func NewParser(tokens []Token) *Parser {
 
 func (p *Parser) Parse() ([]Stmt, error) {
 	var statements []Stmt
-	for !p.isAtEnd() {
+	for p.skipLineTerminators(); !p.isAtEnd(); p.skipLineTerminators() {
 		declaration, err := p.declaration()
 		if err != nil {
 			return nil, err
@@ -37,12 +45,16 @@ func (p *Parser) Parse() ([]Stmt, error) {
 }
 
 func (p *Parser) statement() (Stmt, error) {
+	p.skipLineTerminators()
 	if p.matchSingle(ForToken) {
 		return p.forStatement()
 	}
 	if p.matchSingle(IfToken) {
 		return p.ifStatement()
 	}
+	if p.matchSingle(MatchToken) {
+		return p.matchStatement()
+	}
 	if p.matchSingle(PrintToken) {
 		return p.printStatement()
 	}
@@ -71,6 +83,10 @@ func (p *Parser) forStatement() (Stmt, error) {
 	if p.matchSingle(SemicolonToken) {
 		// Pass.
 	} else if p.matchSingle(VarToken) {
+		// `in` is only a keyword after the variable of a for-in loop.
+		if p.check(IdentifierToken) && p.peekNext().TokenType == IdentifierToken && p.peekNext().Lexeme == "in" {
+			return p.forInStatement()
+		}
 		var err error
 		initializer, err = p.varDeclaration()
 		if err != nil {
@@ -127,6 +143,24 @@ func (p *Parser) forStatement() (Stmt, error) {
 	return body, nil
 }
 
+// Parses the rest of `for (var name in iterable) body` after `var`.
+func (p *Parser) forInStatement() (Stmt, error) {
+	name := p.advance()
+	keyword := p.advance()
+	iterable, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.consume(RightParenToken, "Expect ')' after for-in clauses."); err != nil {
+		return nil, err
+	}
+	body, err := p.statement()
+	if err != nil {
+		return nil, err
+	}
+	return ForInStmt{keyword, name, iterable, body}, nil
+}
+
 func (p *Parser) ifStatement() (Stmt, error) {
 	_, err := p.consume(LeftParenToken, "Expect '(' after 'if'.")
 	if err != nil {
@@ -145,6 +179,7 @@ func (p *Parser) ifStatement() (Stmt, error) {
 		return nil, err
 	}
 	var elseBranch Stmt
+	p.skipLineTerminators()
 	if p.matchSingle(ElseToken) {
 		elseBranch, err = p.statement()
 		if err != nil {
@@ -154,9 +189,184 @@ func (p *Parser) ifStatement() (Stmt, error) {
 	return IfStmt{condition, thenBranch, elseBranch}, nil
 }
 
+func (p *Parser) matchStatement() (Stmt, error) {
+	keyword := p.previous()
+	if _, err := p.consume(LeftParenToken, "Expect '(' after 'match'."); err != nil {
+		return nil, err
+	}
+	subject, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.consume(RightParenToken, "Expect ')' after match value."); err != nil {
+		return nil, err
+	}
+	p.skipLineTerminators()
+	if _, err := p.consume(LeftBraceToken, "Expect '{' before match body."); err != nil {
+		return nil, err
+	}
+	var cases []MatchCase
+	for p.skipLineTerminators(); !p.check(RightBraceToken) && !p.isAtEnd(); p.skipLineTerminators() {
+		matchCase, err := p.matchCase()
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, matchCase)
+	}
+	if _, err := p.consume(RightBraceToken, "Expect '}' after match body."); err != nil {
+		return nil, err
+	}
+	warnUnreachableCases(cases)
+	return MatchStmt{keyword, subject, cases}, nil
+}
+
+func (p *Parser) matchCase() (MatchCase, error) {
+	if p.matchSingle(DefaultToken) {
+		keyword := p.previous()
+		if _, err := p.consume(FatArrowToken, "Expect '=>' after 'default'."); err != nil {
+			return MatchCase{}, err
+		}
+		body, err := p.statement()
+		if err != nil {
+			return MatchCase{}, err
+		}
+		return MatchCase{keyword, nil, nil, body}, nil
+	}
+	keyword, err := p.consume(CaseToken, "Expect 'case' or 'default' in match body.")
+	if err != nil {
+		return MatchCase{}, err
+	}
+	var patterns []Pattern
+	for {
+		pattern, err := p.pattern()
+		if err != nil {
+			return MatchCase{}, err
+		}
+		patterns = append(patterns, pattern)
+		if !p.matchSingle(CommaToken) {
+			break
+		}
+	}
+	if len(patterns) > 1 {
+		for _, pattern := range patterns {
+			if bindsNames(pattern) {
+				PrintDetailedError(pattern.PatternToken(), "Can't bind variables in a case with several patterns.")
+			}
+		}
+	}
+	var guard Expr
+	if p.matchSingle(IfToken) {
+		guard, err = p.expression()
+		if err != nil {
+			return MatchCase{}, err
+		}
+	}
+	if _, err := p.consume(FatArrowToken, "Expect '=>' after case pattern."); err != nil {
+		return MatchCase{}, err
+	}
+	body, err := p.statement()
+	if err != nil {
+		return MatchCase{}, err
+	}
+	return MatchCase{keyword, patterns, guard, body}, nil
+}
+
+func (p *Parser) pattern() (Pattern, error) {
+	if p.matchSingle(FalseToken) {
+		return LiteralPattern{p.previous(), false}, nil
+	}
+	if p.matchSingle(TrueToken) {
+		return LiteralPattern{p.previous(), true}, nil
+	}
+	if p.matchSingle(NilToken) {
+		return LiteralPattern{p.previous(), nil}, nil
+	}
+	if p.match([]TokenType{NumberToken, StringToken}) {
+		return LiteralPattern{p.previous(), p.previous().Literal}, nil
+	}
+	if p.matchSingle(MinusToken) {
+		number, err := p.consume(NumberToken, "Expect number after '-' in pattern.")
+		if err != nil {
+			return nil, err
+		}
+		return LiteralPattern{number, negateNumber(number.Literal)}, nil
+	}
+	if p.matchSingle(IdentifierToken) {
+		name := p.previous()
+		if !p.matchSingle(LeftParenToken) {
+			return BindingPattern{name}, nil
+		}
+		var fields []FieldPattern
+		if !p.check(RightParenToken) {
+			for {
+				field, err := p.consume(IdentifierToken, "Expect field name in class pattern.")
+				if err != nil {
+					return nil, err
+				}
+				var pattern Pattern = BindingPattern{field}
+				if p.matchSingle(ColonToken) {
+					if pattern, err = p.pattern(); err != nil {
+						return nil, err
+					}
+				}
+				fields = append(fields, FieldPattern{field, pattern})
+				if !p.matchSingle(CommaToken) {
+					break
+				}
+			}
+		}
+		if _, err := p.consume(RightParenToken, "Expect ')' after field patterns."); err != nil {
+			return nil, err
+		}
+		return ClassPattern{VariableExpr{name}, fields}, nil
+	}
+	PrintDetailedError(p.peek(), "Expect pattern.")
+	return nil, ParseError{}
+}
+
+// Warns about cases that can never run, either because an earlier case always
+// matches or because an earlier case already matches the same literal.
+func warnUnreachableCases(cases []MatchCase) {
+	var matched []any
+	exhausted := false
+	for _, matchCase := range cases {
+		if exhausted {
+			PrintWarning(matchCase.Keyword, "Unreachable case.")
+			continue
+		}
+		if matchCase.Patterns == nil {
+			exhausted = true
+			continue
+		}
+		for _, pattern := range matchCase.Patterns {
+			literal, ok := pattern.(LiteralPattern)
+			if !ok {
+				continue
+			}
+			for _, value := range matched {
+				if isEqual(value, literal.Value) {
+					PrintWarning(literal.Token, "Unreachable pattern, an earlier case matches the same value.")
+					break
+				}
+			}
+		}
+		if matchCase.Guard != nil {
+			continue
+		}
+		for _, pattern := range matchCase.Patterns {
+			if literal, ok := pattern.(LiteralPattern); ok {
+				matched = append(matched, literal.Value)
+			}
+			if isIrrefutable(pattern) {
+				exhausted = true
+			}
+		}
+	}
+}
+
 func (p *Parser) block() ([]Stmt, error) {
 	var statements []Stmt
-	for !p.check(RightBraceToken) && !p.isAtEnd() {
+	for p.skipLineTerminators(); !p.check(RightBraceToken) && !p.isAtEnd(); p.skipLineTerminators() {
 		declaration, err := p.declaration()
 		if err != nil {
 			return nil, err
@@ -171,6 +381,7 @@ func (p *Parser) block() ([]Stmt, error) {
 }
 
 func (p *Parser) declaration() (Stmt, error) {
+	// Doc comments are attached to the first token of a declaration.
 	if p.matchSingle(ClassToken) {
 		class, err := p.classDeclaration()
 		if err != nil {
@@ -178,14 +389,43 @@ func (p *Parser) declaration() (Stmt, error) {
 		}
 		return class, nil
 	}
+	// `data` is only a keyword in front of `class`.
+	if p.check(IdentifierToken) && p.peek().Lexeme == "data" && p.peekNext().TokenType == ClassToken {
+		doc := p.advance().Doc
+		p.advance()
+		class, err := p.dataClassDeclaration(doc)
+		if err != nil {
+			return nil, err
+		}
+		return class, nil
+	}
+	if p.matchSingle(TraitToken) {
+		trait, err := p.traitDeclaration()
+		if err != nil {
+			return nil, err
+		}
+		return trait, nil
+	}
 	if p.matchSingle(FunToken) {
-		function, err := p.function("function")
+		function, err := p.function("function", p.previous().Doc)
+		if err != nil {
+			return nil, err
+		}
+		return function, nil
+	}
+	if p.matchSingle(AsyncToken) {
+		doc := p.previous().Doc
+		if _, err := p.consume(FunToken, "Expect 'fun' after 'async'."); err != nil {
+			return nil, err
+		}
+		function, err := p.function("function", doc)
 		if err != nil {
 			return nil, err
 		}
+		function.Async = true
 		return function, nil
 	}
-	if p.matchSingle(VarToken) {
+	if p.match([]TokenType{VarToken, ConstToken}) {
 		declaration, err := p.varDeclaration()
 		if err == nil {
 			return declaration, nil
@@ -212,6 +452,7 @@ func (p *Parser) declaration() (Stmt, error) {
 }
 
 func (p *Parser) classDeclaration() (Stmt, error) {
+	doc := p.previous().Doc
 	name, err := p.consume(IdentifierToken, "Expect class name.")
 	if err != nil {
 		return nil, err
@@ -223,39 +464,295 @@ func (p *Parser) classDeclaration() (Stmt, error) {
 		}
 		superclass = VariableExpr{p.previous()}
 	}
-	if _, err = p.consume(LeftBraceToken, "Expect '{' before class body."); err != nil {
+	traits, err := p.traits()
+	if err != nil {
+		return nil, err
+	}
+	methods, err := p.methods("class")
+	if err != nil {
+		return nil, err
+	}
+	return ClassStmt{name, superclass, traits, false, nil, methods, doc}, nil
+}
+
+// Parses a data class after `data class`, e.g. `data class Point(x, y);`.
+// Data classes get a synthesized init that stores the fields and a copy
+// method that takes the fields as named arguments. The body is optional.
+func (p *Parser) dataClassDeclaration(doc string) (Stmt, error) {
+	name, err := p.consume(IdentifierToken, "Expect class name.")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.consume(LeftParenToken, "Expect '(' after data class name."); err != nil {
+		return nil, err
+	}
+	var fields []Param
+	if !p.check(RightParenToken) {
+		for {
+			field, err := p.parameter(fields)
+			if err != nil {
+				return nil, err
+			}
+			if field.Rest {
+				PrintDetailedError(field.Name, "A data class field can't be a rest parameter.")
+			}
+			if hasField(fields, field.Name.Lexeme) {
+				// Left out so that the synthesized methods don't each report it.
+				PrintDetailedError(field.Name, "Duplicate field '"+field.Name.Lexeme+"' in data class.")
+			} else {
+				fields = append(fields, field)
+			}
+			if !p.matchSingle(CommaToken) {
+				break
+			}
+		}
+	}
+	if _, err := p.consume(RightParenToken, "Expect ')' after data class fields."); err != nil {
+		return nil, err
+	}
+	if p.check(LessToken) {
+		PrintDetailedError(p.peek(), "A data class can't have a superclass.")
+		return nil, ParseError{}
+	}
+	traits, err := p.traits()
+	if err != nil {
+		return nil, err
+	}
+	var methods []FunctionStmt
+	if !p.matchSingle(SemicolonToken) {
+		if methods, err = p.methods("class"); err != nil {
+			return nil, err
+		}
+	}
+	for _, method := range methods {
+		if method.Name.Lexeme == "init" || method.Name.Lexeme == "copy" {
+			PrintDetailedError(method.Name, "A data class can't declare '"+method.Name.Lexeme+"'.")
+		}
+	}
+	methods = append(methods, p.dataInit(name, fields), p.dataCopy(name, fields))
+	return ClassStmt{name, VariableExpr{}, traits, true, fields, methods, doc}, nil
+}
+
+func hasField(fields []Param, name string) bool {
+	for _, field := range fields {
+		if field.Name.Lexeme == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Synthesizes `init(fields) { this.field = field; ... }` for a data class.
+func (p *Parser) dataInit(class Token, fields []Param) FunctionStmt {
+	var body []Stmt
+	for _, field := range fields {
+		this := ThisExpr{p.syntheticToken(ThisToken, "this", field.Name)}
+		equals := p.syntheticToken(EqualToken, "=", field.Name)
+		value := VariableExpr{p.syntheticToken(IdentifierToken, field.Name.Lexeme, field.Name)}
+		body = append(body, ExpressionStmt{SetExpr{this, field.Name, equals, value, false}})
+	}
+	name := p.syntheticToken(IdentifierToken, "init", class)
+	return FunctionStmt{name, fields, Token{}, false, false, false, body, ""}
+}
+
+// Synthesizes `copy(field = this.field, ...) { return Class(field, ...); }`
+// for a data class, so that `p.copy(x: 1)` copies p with a new x.
+func (p *Parser) dataCopy(class Token, fields []Param) FunctionStmt {
+	var params []Param
+	var arguments []Expr
+	for _, field := range fields {
+		this := ThisExpr{p.syntheticToken(ThisToken, "this", field.Name)}
+		current := GetExpr{this, field.Name, false}
+		params = append(params, Param{field.Name, field.Type, current, false})
+		arguments = append(arguments, VariableExpr{p.syntheticToken(IdentifierToken, field.Name.Lexeme, field.Name)})
+	}
+	callee := VariableExpr{p.syntheticToken(IdentifierToken, class.Lexeme, class)}
+	call := CallExpr{callee, p.syntheticToken(RightParenToken, ")", class), arguments, nil}
+	body := []Stmt{ReturnStmt{p.syntheticToken(ReturnToken, "return", class), call}}
+	name := p.syntheticToken(IdentifierToken, "copy", class)
+	returnType := p.syntheticToken(IdentifierToken, class.Lexeme, class)
+	return FunctionStmt{name, params, returnType, false, false, false, body, ""}
+}
+
+// Creates a token for code synthesized by the parser, reported at the line of
+// the token at. Synthesized tokens get negative offsets so that they never
+// collide with scanned tokens when used as keys.
+func (p *Parser) syntheticToken(tokenType TokenType, lexeme string, at Token) Token {
+	p.synthesized++
+	return Token{tokenType, lexeme, nil, at.Line, -p.synthesized, ""}
+}
+
+// Parses the traits a class mixes in, e.g. `with Comparable, Printable`.
+func (p *Parser) traits() ([]VariableExpr, error) {
+	var traits []VariableExpr
+	if !p.matchSingle(WithToken) {
+		return nil, nil
+	}
+	for {
+		trait, err := p.consume(IdentifierToken, "Expect trait name.")
+		if err != nil {
+			return nil, err
+		}
+		traits = append(traits, VariableExpr{trait})
+		if !p.matchSingle(CommaToken) {
+			return traits, nil
+		}
+	}
+}
+
+func (p *Parser) traitDeclaration() (Stmt, error) {
+	doc := p.previous().Doc
+	name, err := p.consume(IdentifierToken, "Expect trait name.")
+	if err != nil {
+		return nil, err
+	}
+	methods, err := p.methods("trait")
+	if err != nil {
+		return nil, err
+	}
+	return TraitStmt{name, methods, doc}, nil
+}
+
+// Parses the body of a class or trait.
+func (p *Parser) methods(kind string) ([]FunctionStmt, error) {
+	p.skipLineTerminators()
+	if _, err := p.consume(LeftBraceToken, "Expect '{' before "+kind+" body."); err != nil {
 		return nil, err
 	}
 	var methods []FunctionStmt
-	for !p.check(RightBraceToken) && !p.isAtEnd() {
-		method, err := p.function("method")
+	for p.skipLineTerminators(); !p.check(RightBraceToken) && !p.isAtEnd(); p.skipLineTerminators() {
+		kind, doc := "method", p.peek().Doc
+		// `abstract` is only a keyword in front of a method name, so that
+		// methods can still be called abstract.
+		if next := p.peekNext().TokenType; p.check(IdentifierToken) && p.peek().Lexeme == "abstract" &&
+			(next == IdentifierToken || next == PrivateIdentifierToken) {
+			kind = "abstract method"
+			p.advance()
+		}
+		async := p.matchSingle(AsyncToken)
+		method, err := p.function(kind, doc)
 		if err != nil {
 			return nil, err
 		}
+		method.Async = async
 		methods = append(methods, method)
 	}
-	if _, err := p.consume(RightBraceToken, "Expect '}' after class body."); err != nil {
+	if _, err := p.consume(RightBraceToken, "Expect '}' after "+kind+" body."); err != nil {
 		return nil, err
 	}
-	return ClassStmt{name, superclass, methods}, nil
+	return methods, nil
 }
 
 func (p *Parser) varDeclaration() (Stmt, error) {
+	keyword := p.previous()
+	if p.matchSingle(LeftBraceToken) {
+		return p.fieldDestructuring(keyword)
+	}
 	name, err := p.consume(IdentifierToken, "Expect variable name.")
 	if err != nil {
 		return nil, err
 	}
+	if p.check(CommaToken) {
+		return p.multipleVarDeclaration(keyword, name)
+	}
+	typ, err := p.typeAnnotation()
+	if err != nil {
+		return nil, err
+	}
 	var initializer Expr
 	if p.matchSingle(EqualToken) {
 		initializer, err = p.expression()
 		if err != nil {
 			return nil, err
 		}
+	} else if keyword.TokenType == ConstToken {
+		PrintDetailedError(name, "Constants must be initialized.")
 	}
 	if _, err = p.consume(SemicolonToken, "Expect ';' after variable declaration."); err != nil {
 		return nil, err
 	}
-	return VarStmt{name, initializer}, nil
+	return VarStmt{keyword, name, typ, initializer, keyword.Doc}, nil
+}
+
+// Declares several variables at once, e.g. `var a, b = 1, 2;`.
+func (p *Parser) multipleVarDeclaration(keyword Token, first Token) (Stmt, error) {
+	names := []Token{first}
+	for p.matchSingle(CommaToken) {
+		name, err := p.consume(IdentifierToken, "Expect variable name.")
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	var values []Expr
+	if p.matchSingle(EqualToken) {
+		equals := p.previous()
+		var err error
+		if values, err = p.expressionList(); err != nil {
+			return nil, err
+		}
+		if len(values) != len(names) {
+			PrintDetailedError(equals, fmt.Sprintf("Expect %d values but got %d.", len(names), len(values)))
+		}
+	} else if keyword.TokenType == ConstToken {
+		PrintDetailedError(first, "Constants must be initialized.")
+	}
+	if _, err := p.consume(SemicolonToken, "Expect ';' after variable declaration."); err != nil {
+		return nil, err
+	}
+	return DestructureStmt{keyword, names, nil, values}, nil
+}
+
+// Declares variables from the fields of an instance, e.g. `var {x, y: b} = p;`
+// binds x to p.x and b to p.y.
+func (p *Parser) fieldDestructuring(keyword Token) (Stmt, error) {
+	var names, fields []Token
+	for {
+		field, err := p.consume(IdentifierToken, "Expect field name.")
+		if err != nil {
+			return nil, err
+		}
+		name := field
+		if p.matchSingle(ColonToken) {
+			if name, err = p.consume(IdentifierToken, "Expect variable name after ':'."); err != nil {
+				return nil, err
+			}
+		}
+		fields = append(fields, field)
+		names = append(names, name)
+		if !p.matchSingle(CommaToken) {
+			break
+		}
+	}
+	if _, err := p.consume(RightBraceToken, "Expect '}' after field names."); err != nil {
+		return nil, err
+	}
+	if _, err := p.consume(EqualToken, "Expect '=' after destructuring pattern."); err != nil {
+		return nil, err
+	}
+	value, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.consume(SemicolonToken, "Expect ';' after variable declaration."); err != nil {
+		return nil, err
+	}
+	return DestructureStmt{keyword, names, fields, []Expr{value}}, nil
+}
+
+// Parses one or more comma-separated expressions.
+func (p *Parser) expressionList() ([]Expr, error) {
+	var exprs []Expr
+	for {
+		expr, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+		if !p.matchSingle(CommaToken) {
+			return exprs, nil
+		}
+	}
 }
 
 func (p *Parser) whileStatement() (Stmt, error) {
@@ -310,6 +807,9 @@ func (p *Parser) expressionStatement() (Stmt, error) {
 	if err != nil {
 		return nil, err
 	}
+	if p.check(CommaToken) {
+		return p.multipleAssignment(expr)
+	}
 
 	if _, err = p.consume(SemicolonToken, "Expect ';' after expression."); err != nil {
 		return nil, err
@@ -317,26 +817,70 @@ func (p *Parser) expressionStatement() (Stmt, error) {
 	return ExpressionStmt{expr}, nil
 }
 
-func (p *Parser) function(kind string) (FunctionStmt, error) {
-	name, err := p.consume(IdentifierToken, "Expect "+kind+" name.")
+// Assigns several targets at once, e.g. `a, b = b, a;`.
+func (p *Parser) multipleAssignment(first Expr) (Stmt, error) {
+	targets := []Expr{first}
+	for p.matchSingle(CommaToken) {
+		target, err := p.call()
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	equals, err := p.consume(EqualToken, "Expect '=' after assignment targets.")
+	if err != nil {
+		return nil, err
+	}
+	for _, target := range targets {
+		switch target := target.(type) {
+		case VariableExpr:
+		case GetExpr:
+			if target.Optional {
+				PrintDetailedError(equals, "Invalid assignment target.")
+			}
+		default:
+			PrintDetailedError(equals, "Invalid assignment target.")
+		}
+	}
+	values, err := p.expressionList()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != len(targets) {
+		PrintDetailedError(equals, fmt.Sprintf("Expect %d values but got %d.", len(targets), len(values)))
+	}
+	if _, err = p.consume(SemicolonToken, "Expect ';' after expression."); err != nil {
+		return nil, err
+	}
+	return MultiAssignStmt{targets, equals, values}, nil
+}
+
+func (p *Parser) function(kind string, doc string) (FunctionStmt, error) {
+	var name Token
+	var err error
+	if kind == "method" || kind == "abstract method" {
+		name, err = p.memberName("Expect method name.")
+	} else {
+		name, err = p.consume(IdentifierToken, "Expect "+kind+" name.")
+	}
 	if err != nil {
 		return FunctionStmt{}, err
 	}
 	if _, err := p.consume(LeftParenToken, "Expect '(' after "+kind+" name."); err != nil {
 		return FunctionStmt{}, err
 	}
-	var parameters []Token
+	var parameters []Param
 	// Do-WhileToken loop.
 	if !p.check(RightParenToken) {
 		for {
 			if len(parameters) >= 255 {
 				PrintDetailedError(p.peek(), "Can't have more than 255 parameters.")
 			}
-			token, err := p.consume(IdentifierToken, "Expect parameter name.")
+			param, err := p.parameter(parameters)
 			if err != nil {
 				return FunctionStmt{}, err
 			}
-			parameters = append(parameters, token)
+			parameters = append(parameters, param)
 			if !p.matchSingle(CommaToken) {
 				break
 			}
@@ -348,40 +892,130 @@ func (p *Parser) function(kind string) (FunctionStmt, error) {
 	if _, err := p.consume(RightParenToken, "Expect ')' after parameters."); err != nil {
 		return FunctionStmt{}, err
 	}
+	returnType, err := p.typeAnnotation()
+	if err != nil {
+		return FunctionStmt{}, err
+	}
+	if kind == "abstract method" {
+		if _, err := p.consume(SemicolonToken, "Expect ';' after abstract method declaration."); err != nil {
+			return FunctionStmt{}, err
+		}
+		if name.Lexeme == "init" {
+			PrintDetailedError(name, "An initializer can't be abstract.")
+		} else if name.TokenType == PrivateIdentifierToken {
+			PrintDetailedError(name, "A private method can't be abstract.")
+		}
+		return FunctionStmt{name, parameters, returnType, true, false, false, nil, doc}, nil
+	}
+	p.skipLineTerminators()
 	if _, err := p.consume(LeftBraceToken, "Expect '{' before "+kind+" body."); err != nil {
 		return FunctionStmt{}, err
 	}
+	enclosingYielded := p.yielded
+	p.yielded = false
 	body, err := p.block()
 	if err != nil {
 		return FunctionStmt{}, err
 	}
-	return FunctionStmt{name, parameters, body}, nil
+	generator := p.yielded
+	p.yielded = enclosingYielded
+	return FunctionStmt{name, parameters, returnType, false, false, generator, body, doc}, nil
+}
+
+// Parses a parameter given the ones before it. Parameters with defaults must
+// follow the ones without, and a rest parameter must be last.
+func (p *Parser) parameter(previous []Param) (Param, error) {
+	if len(previous) > 0 && previous[len(previous)-1].Rest {
+		PrintDetailedError(previous[len(previous)-1].Name, "A rest parameter must be the last parameter.")
+	}
+	if p.matchSingle(DotDotDotToken) {
+		name, err := p.consume(IdentifierToken, "Expect parameter name after '...'.")
+		if err != nil {
+			return Param{}, err
+		}
+		typ, err := p.typeAnnotation()
+		if err != nil {
+			return Param{}, err
+		}
+		return Param{name, typ, nil, true}, nil
+	}
+	name, err := p.consume(IdentifierToken, "Expect parameter name.")
+	if err != nil {
+		return Param{}, err
+	}
+	typ, err := p.typeAnnotation()
+	if err != nil {
+		return Param{}, err
+	}
+	var value Expr
+	if p.matchSingle(EqualToken) {
+		if value, err = p.expression(); err != nil {
+			return Param{}, err
+		}
+	} else if len(previous) > 0 && previous[len(previous)-1].Default != nil {
+		PrintDetailedError(name, "A parameter without a default can't follow one with a default.")
+	}
+	return Param{name, typ, value, false}, nil
+}
+
+// Parses the name of a property or method, which may be private.
+func (p *Parser) memberName(message string) (Token, error) {
+	if p.matchSingle(PrivateIdentifierToken) {
+		return p.previous(), nil
+	}
+	return p.consume(IdentifierToken, message)
+}
+
+// Parses an optional type annotation such as `: Number`. Annotations are only
+// used by `glox check` and are ignored at runtime. Returns the zero Token if
+// there is no annotation.
+func (p *Parser) typeAnnotation() (Token, error) {
+	if !p.matchSingle(ColonToken) {
+		return Token{}, nil
+	}
+	return p.consume(IdentifierToken, "Expect type name after ':'.")
 }
 
 func (p *Parser) expression() (Expr, error) {
 	return p.assignment()
 }
 
+// Parses a yield expression after `yield`. The value is optional, so that
+// `yield;` yields nil.
+func (p *Parser) yield() (Expr, error) {
+	keyword := p.previous()
+	p.yielded = true
+	if p.check(SemicolonToken) || p.check(RightParenToken) || p.check(RightBraceToken) ||
+		p.check(CommaToken) || p.check(ColonToken) {
+		return YieldExpr{keyword, nil}, nil
+	}
+	value, err := p.assignment()
+	if err != nil {
+		return nil, err
+	}
+	return YieldExpr{keyword, value}, nil
+}
+
 // Assignment is right-associative.
 // We can do this since every valid assignment target is a valid expression.
 func (p *Parser) assignment() (Expr, error) {
-	expr, err := p.or()
+	if p.matchSingle(YieldToken) {
+		return p.yield()
+	}
+	expr, err := p.conditional()
 	if err != nil {
 		return nil, err
 	}
 
-	if p.matchSingle(EqualToken) {
+	if p.match([]TokenType{EqualToken, PlusEqualToken, MinusEqualToken, StarEqualToken, SlashEqualToken}) {
 		equals := p.previous()
 		value, err := p.assignment()
 		if err != nil {
 			return nil, err
 		}
 
-		if ve, ok := expr.(VariableExpr); ok {
-			name := ve.Name
-			return AssignExpr{name, value}, nil
-		} else if get, ok := expr.(GetExpr); ok {
-			return SetExpr{get.Object, get.Name, value}, nil
+		if target, ok := assignmentTarget(expr, equals, value, false); ok {
+			return target, nil
 		}
 		// We don't throw an error because the parser is not in a bad state.
 		PrintDetailedError(equals, "Invalid assignment target.")
@@ -389,6 +1023,60 @@ func (p *Parser) assignment() (Expr, error) {
 	return expr, nil
 }
 
+// Turns a variable or property access into an assignment to it. `++` and `--`
+// are stored as assignments that add or subtract one.
+func assignmentTarget(expr Expr, operator Token, value Expr, postfix bool) (Expr, bool) {
+	if ve, ok := expr.(VariableExpr); ok {
+		name := ve.Name
+		return AssignExpr{name, operator, value, postfix}, true
+	} else if get, ok := expr.(GetExpr); ok && !get.Optional {
+		return SetExpr{get.Object, get.Name, operator, value, postfix}, true
+	}
+	return nil, false
+}
+
+// The conditional operator is right-associative, so `a ? b : c ? d : e` is
+// `a ? b : (c ? d : e)`.
+func (p *Parser) conditional() (Expr, error) {
+	condition, err := p.coalesce()
+	if err != nil {
+		return nil, err
+	}
+	if !p.matchSingle(QuestionToken) {
+		return condition, nil
+	}
+	thenBranch, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.consume(ColonToken, "Expect ':' after then branch of conditional expression."); err != nil {
+		return nil, err
+	}
+	elseBranch, err := p.conditional()
+	if err != nil {
+		return nil, err
+	}
+	return ConditionalExpr{condition, thenBranch, elseBranch}, nil
+}
+
+// `??` evaluates to its left operand unless it is nil, in which case the
+// right operand is evaluated.
+func (p *Parser) coalesce() (Expr, error) {
+	expr, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	for p.matchSingle(QuestionQuestionToken) {
+		operator := p.previous()
+		right, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		expr = LogicalExpr{expr, operator, right}
+	}
+	return expr, nil
+}
+
 func (p *Parser) or() (Expr, error) {
 	expr, err := p.and()
 	if err != nil {
@@ -438,11 +1126,77 @@ func (p *Parser) equality() (Expr, error) {
 }
 
 func (p *Parser) comparison() (Expr, error) {
+	expr, err := p.bitwiseOr()
+	if err != nil {
+		return nil, err
+	}
+	for p.match([]TokenType{GreaterToken, GreaterEqualToken, LessToken, LessEqualToken, IsToken}) {
+		operator := p.previous()
+		right, err := p.bitwiseOr()
+		if err != nil {
+			return nil, err
+		}
+		expr = BinaryExpr{expr, operator, right}
+	}
+	return expr, nil
+}
+
+// Bitwise operators bind tighter than comparisons so that `a & b == 0` reads
+// as `(a & b) == 0`.
+func (p *Parser) bitwiseOr() (Expr, error) {
+	expr, err := p.bitwiseXor()
+	if err != nil {
+		return nil, err
+	}
+	for p.match([]TokenType{PipeToken}) {
+		operator := p.previous()
+		right, err := p.bitwiseXor()
+		if err != nil {
+			return nil, err
+		}
+		expr = BinaryExpr{expr, operator, right}
+	}
+	return expr, nil
+}
+
+func (p *Parser) bitwiseXor() (Expr, error) {
+	expr, err := p.bitwiseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.match([]TokenType{CaretToken}) {
+		operator := p.previous()
+		right, err := p.bitwiseAnd()
+		if err != nil {
+			return nil, err
+		}
+		expr = BinaryExpr{expr, operator, right}
+	}
+	return expr, nil
+}
+
+func (p *Parser) bitwiseAnd() (Expr, error) {
+	expr, err := p.shift()
+	if err != nil {
+		return nil, err
+	}
+	for p.match([]TokenType{AmpersandToken}) {
+		operator := p.previous()
+		right, err := p.shift()
+		if err != nil {
+			return nil, err
+		}
+		expr = BinaryExpr{expr, operator, right}
+	}
+	return expr, nil
+}
+
+func (p *Parser) shift() (Expr, error) {
 	expr, err := p.term()
 	if err != nil {
 		return nil, err
 	}
-	for p.match([]TokenType{GreaterToken, GreaterEqualToken, LessToken, LessEqualToken}) {
+	for p.match([]TokenType{LessLessToken, GreaterGreaterToken}) {
 		operator := p.previous()
 		right, err := p.term()
 		if err != nil {
@@ -474,7 +1228,7 @@ func (p *Parser) factor() (Expr, error) {
 	if err != nil {
 		return nil, err
 	}
-	for p.match([]TokenType{SlashToken, StarToken}) {
+	for p.match([]TokenType{SlashToken, StarToken, PercentToken}) {
 		operator := p.previous()
 		right, err := p.unary()
 		if err != nil {
@@ -486,7 +1240,19 @@ func (p *Parser) factor() (Expr, error) {
 }
 
 func (p *Parser) unary() (Expr, error) {
-	if p.match([]TokenType{BangToken, MinusToken}) {
+	if p.match([]TokenType{PlusPlusToken, MinusMinusToken}) {
+		operator := p.previous()
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if target, ok := assignmentTarget(operand, operator, LiteralExpr{int64(1)}, false); ok {
+			return target, nil
+		}
+		PrintDetailedError(operator, "Invalid increment target.")
+		return operand, nil
+	}
+	if p.match([]TokenType{BangToken, MinusToken, TildeToken}) {
 		operator := p.previous()
 		right, err := p.unary()
 		if err != nil {
@@ -494,36 +1260,90 @@ func (p *Parser) unary() (Expr, error) {
 		}
 		return UnaryExpr{operator, right}, nil
 	}
-	return p.call()
+	if p.matchSingle(AwaitToken) {
+		keyword := p.previous()
+		value, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return AwaitExpr{keyword, value}, nil
+	}
+	return p.power()
 }
 
-func (p *Parser) finishCall(callee Expr) (Expr, error) {
-	var arguments []Expr
-	if !p.check(RightParenToken) {
-		expression, err := p.expression()
+// Exponentiation is right-associative and binds tighter than unary operators
+// on its left, so `-2 ** 2` is `-(2 ** 2)` and `2 ** 3 ** 2` is `2 ** (3 ** 2)`.
+// The exponent is a unary so that `2 ** -1` works.
+func (p *Parser) power() (Expr, error) {
+	expr, err := p.postfix()
+	if err != nil {
+		return nil, err
+	}
+	if p.matchSingle(StarStarToken) {
+		operator := p.previous()
+		right, err := p.unary()
 		if err != nil {
 			return nil, err
 		}
-		arguments = append(arguments, expression)
-		for p.matchSingle(CommaToken) {
+		expr = BinaryExpr{expr, operator, right}
+	}
+	return expr, nil
+}
+
+func (p *Parser) postfix() (Expr, error) {
+	expr, err := p.call()
+	if err != nil {
+		return nil, err
+	}
+	if p.match([]TokenType{PlusPlusToken, MinusMinusToken}) {
+		operator := p.previous()
+		if target, ok := assignmentTarget(expr, operator, LiteralExpr{int64(1)}, true); ok {
+			return target, nil
+		}
+		PrintDetailedError(operator, "Invalid increment target.")
+	}
+	return expr, nil
+}
+
+// Named arguments, e.g. `f(1, b: 2)`, must follow the positional ones.
+func (p *Parser) finishCall(callee Expr) (Expr, error) {
+	var arguments []Expr
+	var names []Token
+	if !p.check(RightParenToken) {
+		for {
 			// Go doesn't seem to have a limit. So, use 255 (Java's limit).
 			// Only report an error but don't throw since the Parser is in a
 			// valid state.
-			if len(arguments) > 255 {
+			if len(arguments) >= 255 {
 				PrintDetailedError(p.peek(), "Can't have more than 255 arguments.")
 			}
-			expression, err = p.expression()
+			if p.check(IdentifierToken) && p.peekNext().TokenType == ColonToken {
+				name := p.advance()
+				p.advance()
+				for _, other := range names {
+					if other.Lexeme == name.Lexeme {
+						PrintDetailedError(name, "Argument '"+name.Lexeme+"' is passed more than once.")
+					}
+				}
+				names = append(names, name)
+			} else if len(names) > 0 {
+				PrintDetailedError(p.peek(), "Positional arguments can't follow named arguments.")
+			}
+			expression, err := p.expression()
 			if err != nil {
 				return nil, err
 			}
 			arguments = append(arguments, expression)
+			if !p.matchSingle(CommaToken) {
+				break
+			}
 		}
 	}
 	paren, err := p.consume(RightParenToken, "Expect ')' after arguments.")
 	if err != nil {
 		return nil, err
 	}
-	return CallExpr{callee, paren, arguments}, nil
+	return CallExpr{callee, paren, arguments, names}, nil
 }
 
 func (p *Parser) call() (Expr, error) {
@@ -537,12 +1357,13 @@ func (p *Parser) call() (Expr, error) {
 			if err != nil {
 				return nil, err
 			}
-		} else if p.matchSingle(DotToken) {
-			name, err := p.consume(IdentifierToken, "Expect property name after '.'.")
+		} else if p.match([]TokenType{DotToken, QuestionDotToken}) {
+			optional := p.previous().TokenType == QuestionDotToken
+			name, err := p.memberName("Expect property name after '" + p.previous().Lexeme + "'.")
 			if err != nil {
 				return nil, err
 			}
-			expr = GetExpr{expr, name}
+			expr = GetExpr{expr, name, optional}
 		} else {
 			break
 		}
@@ -620,6 +1441,10 @@ func (p *Parser) consume(tokenType TokenType, message string) (Token, error) {
 	if p.check(tokenType) {
 		return p.advance(), nil
 	}
+	if tokenType == SemicolonToken && p.optionalSemicolons && p.check(RightBraceToken) {
+		// Like Go, allow `{ return x }` on a single line.
+		return Token{SemicolonToken, "\n", nil, p.peek().Line, p.peek().Offset, ""}, nil
+	}
 	// NOTE: ThisToken deviates from Ch. 6 error reporting since Go does not support
 	// throwing errors.
 	PrintDetailedError(p.peek(), message)
@@ -648,6 +1473,22 @@ func (p *Parser) peek() Token {
 	return p.tokens[p.current]
 }
 
+// Returns the token after the next one without consuming anything.
+func (p *Parser) peekNext() Token {
+	if p.isAtEnd() {
+		return p.peek()
+	}
+	return p.tokens[p.current+1]
+}
+
+// Skips semicolons inserted at line ends in optional semicolon mode where no
+// statement ends, e.g. between `}` and `else` or after a block.
+func (p *Parser) skipLineTerminators() {
+	for p.check(SemicolonToken) && p.peek().Lexeme == "\n" {
+		p.advance()
+	}
+}
+
 func (p *Parser) previous() Token {
 	return p.tokens[p.current-1]
 }
@@ -664,14 +1505,20 @@ func (p *Parser) synchronize() {
 		switch p.peek().TokenType {
 		case ClassToken:
 			return
+		case TraitToken:
+			return
 		case FunToken:
 			return
 		case VarToken:
 			return
+		case ConstToken:
+			return
 		case ForToken:
 			return
 		case IfToken:
 			return
+		case MatchToken:
+			return
 		case WhileToken:
 			return
 		case PrintToken:
@@ -0,0 +1,76 @@
+package main
+
+// A case of a match statement. A default case has no patterns.
+type MatchCase struct {
+	// The `case` or `default` keyword.
+	Keyword Token
+	// Alternatives, any of which makes the case match.
+	Patterns []Pattern
+	// Optional condition that must also hold for the case to match.
+	Guard Expr
+	Body  Stmt
+}
+
+// Patterns are matched against a value in a match statement.
+type Pattern interface {
+	// Token used to report errors about the pattern.
+	PatternToken() Token
+}
+
+// Matches values equal to a literal, e.g. `1`, `"x"` or `nil`.
+type LiteralPattern struct {
+	Token Token
+	Value any
+}
+
+// Matches any value and binds it to Name. `_` matches without binding.
+type BindingPattern struct {
+	Name Token
+}
+
+// Matches instances of Class or one of its subclasses whose fields match
+// Fields, e.g. `Point(x, y: 0)`.
+type ClassPattern struct {
+	Class  VariableExpr
+	Fields []FieldPattern
+}
+
+// Matches the field Name of an instance against Pattern. A bare field name
+// binds the field to a variable of the same name.
+type FieldPattern struct {
+	Name    Token
+	Pattern Pattern
+}
+
+func (p LiteralPattern) PatternToken() Token {
+	return p.Token
+}
+
+func (p BindingPattern) PatternToken() Token {
+	return p.Name
+}
+
+func (p ClassPattern) PatternToken() Token {
+	return p.Class.Name
+}
+
+// Whether the pattern matches every value.
+func isIrrefutable(pattern Pattern) bool {
+	_, ok := pattern.(BindingPattern)
+	return ok
+}
+
+// Whether the pattern binds a variable.
+func bindsNames(pattern Pattern) bool {
+	switch pattern := pattern.(type) {
+	case BindingPattern:
+		return pattern.Name.Lexeme != "_"
+	case ClassPattern:
+		for _, field := range pattern.Fields {
+			if bindsNames(field.Pattern) {
+				return true
+			}
+		}
+	}
+	return false
+}
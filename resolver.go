@@ -16,28 +16,46 @@ const (
 	NoneClass ClassType = iota
 	InClass
 	SubClass
+	InTrait
 )
 
 type Resolver struct {
-	interpreter     *Interpreter
-	scopes          []map[string]bool
+	interpreter *Interpreter
+	scopes      []map[string]bool
+	// Names declared with `const` in the scope at the same index in scopes.
+	constants       []map[string]bool
 	currentFunction FunctionType
 	currentClass    ClassType
+	// Whether the function being resolved is async.
+	inAsync bool
+	// Whether calls returned by the function being resolved can be tail calls.
+	// Generators and async functions return them to their caller some other
+	// way, and initializers return `this`.
+	tailCalls bool
+	// Classes and traits declared so far by name, used to check that classes
+	// implement the abstract methods they inherit.
+	classes map[string]ClassStmt
+	traits  map[string]TraitStmt
 }
 
 func NewResolver(interpreter *Interpreter) Resolver {
 	return Resolver{
 		interpreter:     interpreter,
 		scopes:          []map[string]bool{},
+		constants:       []map[string]bool{},
 		currentFunction: NoneFunction,
 		currentClass:    NoneClass,
+		classes:         map[string]ClassStmt{},
+		traits:          map[string]TraitStmt{},
 	}
 }
 
 func (r *Resolver) VisitAssignExpr(expr AssignExpr) (any, error) {
-	fmt.Println("VisistAssignExpr")
 	r.resolveExpr(expr.Value)
-	r.resolveLocal(expr, expr.Name)
+	r.checkAssignable(expr.Name)
+	// Resolve the name rather than the whole expression: the value may contain
+	// nodes that can't be used as map keys, e.g. calls.
+	r.resolveLocal(VariableExpr{expr.Name}, expr.Name)
 	return nil, nil
 }
 
@@ -47,6 +65,39 @@ func (r *Resolver) VisitVarStmt(stmt VarStmt) (any, error) {
 		r.resolveExpr(stmt.Initializer)
 	}
 	r.define(stmt.Name)
+	if stmt.Keyword.TokenType == ConstToken {
+		r.defineConstant(stmt.Name)
+	}
+	return nil, nil
+}
+
+func (r *Resolver) VisitDestructureStmt(stmt DestructureStmt) (any, error) {
+	for _, name := range stmt.Names {
+		r.declare(name)
+	}
+	for _, value := range stmt.Values {
+		r.resolveExpr(value)
+	}
+	for _, name := range stmt.Names {
+		r.define(name)
+		if stmt.Keyword.TokenType == ConstToken {
+			r.defineConstant(name)
+		}
+	}
+	return nil, nil
+}
+
+func (r *Resolver) VisitMultiAssignStmt(stmt MultiAssignStmt) (any, error) {
+	for _, value := range stmt.Values {
+		r.resolveExpr(value)
+	}
+	// Variable targets are resolved like reads, which records their depth.
+	for _, target := range stmt.Targets {
+		if variable, ok := target.(VariableExpr); ok {
+			r.checkAssignable(variable.Name)
+		}
+		r.resolveExpr(target)
+	}
 	return nil, nil
 }
 
@@ -64,7 +115,15 @@ func (r *Resolver) VisitCallExpr(expr CallExpr) (any, error) {
 	return nil, nil
 }
 
+func (r *Resolver) VisitConditionalExpr(expr ConditionalExpr) (any, error) {
+	r.resolveExpr(expr.Condition)
+	r.resolveExpr(expr.ThenBranch)
+	r.resolveExpr(expr.ElseBranch)
+	return nil, nil
+}
+
 func (r *Resolver) VisitGetExpr(expr GetExpr) (any, error) {
+	r.checkPrivateAccess(expr.Name)
 	r.resolveExpr(expr.Object)
 	// Properties are resolved dynamically.
 	return nil, nil
@@ -86,6 +145,7 @@ func (r *Resolver) VisitLogicalExpr(expr LogicalExpr) (any, error) {
 }
 
 func (r *Resolver) VisitSetExpr(expr SetExpr) (any, error) {
+	r.checkPrivateAccess(expr.Name)
 	r.resolveExpr(expr.Value)
 	r.resolveExpr(expr.Object)
 	return nil, nil
@@ -94,6 +154,8 @@ func (r *Resolver) VisitSetExpr(expr SetExpr) (any, error) {
 func (r *Resolver) VisitSuperExpr(expr SuperExpr) (any, error) {
 	if r.currentClass == NoneClass {
 		PrintDetailedError(expr.Keyword, "Can't use 'super' outside of a class.")
+	} else if r.currentClass == InTrait {
+		PrintDetailedError(expr.Keyword, "Can't use 'super' in a trait.")
 	} else if r.currentClass != SubClass {
 		PrintDetailedError(expr.Keyword, "Can't use 'super' in a class with no superclass.")
 	}
@@ -101,6 +163,28 @@ func (r *Resolver) VisitSuperExpr(expr SuperExpr) (any, error) {
 	return nil, nil
 }
 
+func (r *Resolver) VisitAwaitExpr(expr AwaitExpr) (any, error) {
+	if !r.inAsync {
+		PrintDetailedError(expr.Keyword, "Can only await in an async function.")
+	}
+	r.resolveExpr(expr.Value)
+	return nil, nil
+}
+
+func (r *Resolver) VisitYieldExpr(expr YieldExpr) (any, error) {
+	if r.currentFunction == NoneFunction {
+		PrintDetailedError(expr.Keyword, "Can't yield outside of a function.")
+	} else if r.currentFunction == InitializerFunction {
+		PrintDetailedError(expr.Keyword, "Can't yield from an initializer.")
+	} else if r.inAsync {
+		PrintDetailedError(expr.Keyword, "Can't yield from an async function.")
+	}
+	if expr.Value != nil {
+		r.resolveExpr(expr.Value)
+	}
+	return nil, nil
+}
+
 func (r *Resolver) VisitThisExpr(expr ThisExpr) (any, error) {
 	if r.currentClass == NoneClass {
 		PrintDetailedError(expr.Keyword, "Can't use 'this' outside of a class.")
@@ -155,6 +239,9 @@ func (r *Resolver) VisitClassStmt(stmt ClassStmt) (any, error) {
 	if stmt.Superclass != (VariableExpr{}) && stmt.Name.Lexeme == stmt.Superclass.Name.Lexeme {
 		PrintDetailedError(stmt.Superclass.Name, "A class can't inherit from itself.")
 	}
+	for _, trait := range stmt.Traits {
+		r.resolveExpr(trait)
+	}
 
 	if stmt.Superclass != (VariableExpr{}) {
 		r.currentClass = SubClass
@@ -178,6 +265,111 @@ func (r *Resolver) VisitClassStmt(stmt ClassStmt) (any, error) {
 		r.endScope()
 	}
 	r.currentClass = enclosingClass
+	r.checkAbstractMethods(stmt)
+	r.classes[stmt.Name.Lexeme] = stmt
+	return nil, nil
+}
+
+// An abstract method and the class or trait that declares it.
+type abstractMethod struct {
+	method FunctionStmt
+	owner  string
+}
+
+// Checks that a class implements the abstract methods of its superclasses and
+// traits, with implementations that accept their arguments. A class that
+// leaves a method unimplemented gets a warning rather than an error, since it
+// may be meant to be subclassed, and none if it declares abstract methods
+// itself. Only superclasses and traits declared earlier in the program are
+// checked.
+func (r *Resolver) checkAbstractMethods(stmt ClassStmt) {
+	methods, required := r.staticMethods(stmt, map[string]bool{})
+	isAbstract := false
+	for _, method := range stmt.Methods {
+		isAbstract = isAbstract || method.Abstract
+	}
+	checked := map[string]bool{}
+	for _, abstract := range required {
+		name := abstract.method.Name.Lexeme
+		if checked[name] {
+			continue
+		}
+		checked[name] = true
+		method, found := methods[name]
+		if !found || method.Abstract {
+			if !isAbstract {
+				PrintWarning(stmt.Name, fmt.Sprintf(
+					"Class %s doesn't implement abstract method '%s' of %s.", stmt.Name.Lexeme, name, abstract.owner))
+			}
+			continue
+		}
+		declared := NewFunction(abstract.method, nil, false)
+		implemented := NewFunction(method, nil, false)
+		if implemented.MinArity() > declared.MinArity() ||
+			implemented.MaxArity() != -1 && (declared.MaxArity() == -1 || implemented.MaxArity() < declared.MaxArity()) {
+			PrintDetailedError(method.Name, fmt.Sprintf(
+				"Method '%s' must accept the arguments of abstract method '%s' of %s.", name, name, abstract.owner))
+		}
+	}
+}
+
+// Returns the methods of a class as the interpreter would find them, and the
+// abstract methods it inherits from its superclasses and traits. visited
+// guards against inheritance cycles.
+func (r *Resolver) staticMethods(stmt ClassStmt, visited map[string]bool) (map[string]FunctionStmt, []abstractMethod) {
+	visited[stmt.Name.Lexeme] = true
+	methods := map[string]FunctionStmt{}
+	var required []abstractMethod
+	if superclass, found := r.classes[stmt.Superclass.Name.Lexeme]; found && !visited[superclass.Name.Lexeme] {
+		inherited, abstract := r.staticMethods(superclass, visited)
+		methods = inherited
+		required = abstract
+		for _, method := range superclass.Methods {
+			if method.Abstract {
+				required = append(required, abstractMethod{method, superclass.Name.Lexeme})
+			}
+		}
+	}
+	for _, expr := range stmt.Traits {
+		trait, found := r.traits[expr.Name.Lexeme]
+		if !found {
+			continue
+		}
+		for _, method := range trait.Methods {
+			if method.Abstract {
+				required = append(required, abstractMethod{method, trait.Name.Lexeme})
+				if _, found := methods[method.Name.Lexeme]; found {
+					continue
+				}
+			}
+			methods[method.Name.Lexeme] = method
+		}
+	}
+	for _, method := range stmt.Methods {
+		methods[method.Name.Lexeme] = method
+	}
+	return methods, required
+}
+
+func (r *Resolver) VisitTraitStmt(stmt TraitStmt) (any, error) {
+	enclosingClass := r.currentClass
+	r.currentClass = InTrait
+	r.declare(stmt.Name)
+	r.define(stmt.Name)
+	r.beginScope()
+	r.scopes[len(r.scopes)-1]["this"] = true
+	for _, method := range stmt.Methods {
+		declaration := Method
+		if method.Name.Lexeme == "init" {
+			declaration = InitializerFunction
+		}
+		if err := r.resolveFunction(method, declaration); err != nil {
+			return nil, err
+		}
+	}
+	r.endScope()
+	r.currentClass = enclosingClass
+	r.traits[stmt.Name.Lexeme] = stmt
 	return nil, nil
 }
 
@@ -212,6 +404,40 @@ func (r *Resolver) VisitIfStmt(stmt IfStmt) (any, error) {
 	return nil, nil
 }
 
+func (r *Resolver) VisitMatchStmt(stmt MatchStmt) (any, error) {
+	r.resolveExpr(stmt.Subject)
+	for _, matchCase := range stmt.Cases {
+		// Each case gets its own scope for the variables bound by its patterns.
+		r.beginScope()
+		for _, pattern := range matchCase.Patterns {
+			r.resolvePattern(pattern)
+		}
+		if matchCase.Guard != nil {
+			r.resolveExpr(matchCase.Guard)
+		}
+		if err := r.resolveStmt(matchCase.Body); err != nil {
+			return nil, err
+		}
+		r.endScope()
+	}
+	return nil, nil
+}
+
+func (r *Resolver) resolvePattern(pattern Pattern) {
+	switch pattern := pattern.(type) {
+	case BindingPattern:
+		if pattern.Name.Lexeme != "_" {
+			r.declare(pattern.Name)
+			r.define(pattern.Name)
+		}
+	case ClassPattern:
+		r.resolveExpr(pattern.Class)
+		for _, field := range pattern.Fields {
+			r.resolvePattern(field.Pattern)
+		}
+	}
+}
+
 func (r *Resolver) VisitPrintStmt(stmt PrintStmt) (any, error) {
 	r.resolveExpr(stmt.Expression)
 	return nil, nil
@@ -226,7 +452,22 @@ func (r *Resolver) VisitReturnStmt(stmt ReturnStmt) (any, error) {
 			PrintDetailedError(stmt.Keyword, "Can't return a value from an initializer.")
 		}
 		r.resolveExpr(stmt.Value)
+		if _, ok := stmt.Value.(CallExpr); ok && r.tailCalls {
+			r.interpreter.ResolveTailCall(stmt)
+		}
+	}
+	return nil, nil
+}
+
+func (r *Resolver) VisitForInStmt(stmt ForInStmt) (any, error) {
+	r.resolveExpr(stmt.Iterable)
+	r.beginScope()
+	r.declare(stmt.Name)
+	r.define(stmt.Name)
+	if err := r.resolveStmt(stmt.Body); err != nil {
+		return nil, err
 	}
+	r.endScope()
 	return nil, nil
 }
 
@@ -249,10 +490,12 @@ func (r *Resolver) resolveStmt(stmt Stmt) error {
 
 func (r *Resolver) beginScope() {
 	r.scopes = append(r.scopes, map[string]bool{})
+	r.constants = append(r.constants, map[string]bool{})
 }
 
 func (r *Resolver) endScope() {
 	r.scopes = r.scopes[:len(r.scopes)-1]
+	r.constants = r.constants[:len(r.constants)-1]
 }
 
 func (r *Resolver) declare(name Token) {
@@ -273,8 +516,36 @@ func (r *Resolver) define(name Token) {
 	r.scopes[len(r.scopes)-1][name.Lexeme] = true
 }
 
+// Marks a name in the innermost scope as a constant. Constant globals are
+// checked at runtime by Environment.
+func (r *Resolver) defineConstant(name Token) {
+	if len(r.scopes) == 0 {
+		return
+	}
+	r.constants[len(r.constants)-1][name.Lexeme] = true
+}
+
+// Reports an assignment to a local constant.
+func (r *Resolver) checkAssignable(name Token) {
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		if _, found := r.scopes[i][name.Lexeme]; found {
+			if r.constants[i][name.Lexeme] {
+				PrintDetailedError(name, "Can't assign to constant '"+name.Lexeme+"'.")
+			}
+			return
+		}
+	}
+}
+
+// Reports accesses to private members outside of any class. Accesses through
+// something other than `this` inside a class are reported at runtime.
+func (r *Resolver) checkPrivateAccess(name Token) {
+	if isPrivate(name) && r.currentClass == NoneClass {
+		PrintDetailedError(name, "Can't access private member '"+name.Lexeme+"' outside of a class.")
+	}
+}
+
 func (r *Resolver) resolveLocal(expr Expr, name Token) {
-	fmt.Println("resolveLocal", r.scopes)
 	n := len(r.scopes)
 	for i := n - 1; i >= 0; i-- {
 		if _, found := r.scopes[i][name.Lexeme]; found {
@@ -298,16 +569,29 @@ func (r *Resolver) resolveFunction(function FunctionStmt, typ FunctionType) erro
 	// Stash previous value of the field in local variable first.
 	enclosingFunction := r.currentFunction
 	r.currentFunction = typ
+	enclosingAsync := r.inAsync
+	r.inAsync = function.Async
+	enclosingTailCalls := r.tailCalls
+	r.tailCalls = !function.Async && !function.Generator && typ != InitializerFunction
+	if function.Async && typ == InitializerFunction {
+		PrintDetailedError(function.Name, "An initializer can't be async.")
+	}
 	r.beginScope()
 	for _, param := range function.Params {
-		r.declare(param)
-		r.define(param)
+		// A default can refer to the parameters before it.
+		if param.Default != nil {
+			r.resolveExpr(param.Default)
+		}
+		r.declare(param.Name)
+		r.define(param.Name)
 	}
 	if _, err := r.resolveAll(function.Body); err != nil {
 		return err
 	}
 	r.endScope()
 	r.currentFunction = enclosingFunction
+	r.inAsync = enclosingAsync
+	r.tailCalls = enclosingTailCalls
 	return nil
 }
 
@@ -4,8 +4,21 @@ import "fmt"
 
 type FunctionReturn struct {
 	Value any
+	// If set, the function returns the result of this call instead of Value.
+	// Function.Call makes the call itself, so that tail calls don't grow the
+	// Go stack.
+	TailCall *TailCall
 }
 
 func (fr FunctionReturn) Error() string {
 	return fmt.Sprintf("Error: %v", fr.Value)
 }
+
+// A call in tail position, e.g. `return f(n - 1);`, whose callee and
+// arguments are evaluated.
+type TailCall struct {
+	Function  *Function
+	Arguments []any
+	// Where errors from the call are reported, like CallExpr.Paren.
+	Paren Token
+}
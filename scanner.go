@@ -3,48 +3,71 @@ package main
 import (
 	"fmt"
 	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 var reservedWords = map[string]TokenType{
-	"and":    AndToken,
-	"class":  ClassToken,
-	"else":   ElseToken,
-	"false":  FalseToken,
-	"for":    ForToken,
-	"fun":    FunToken,
-	"if":     IfToken,
-	"nil":    NilToken,
-	"or":     OrToken,
-	"print":  PrintToken,
-	"return": ReturnToken,
-	"super":  SuperToken,
-	"this":   ThisToken,
-	"true":   TrueToken,
-	"var":    VarToken,
-	"while":  WhileToken,
+	"and":     AndToken,
+	"async":   AsyncToken,
+	"await":   AwaitToken,
+	"case":    CaseToken,
+	"class":   ClassToken,
+	"const":   ConstToken,
+	"default": DefaultToken,
+	"else":    ElseToken,
+	"false":   FalseToken,
+	"for":     ForToken,
+	"fun":     FunToken,
+	"if":      IfToken,
+	"is":      IsToken,
+	"match":   MatchToken,
+	"nil":     NilToken,
+	"or":      OrToken,
+	"print":   PrintToken,
+	"return":  ReturnToken,
+	"super":   SuperToken,
+	"this":    ThisToken,
+	"trait":   TraitToken,
+	"true":    TrueToken,
+	"var":     VarToken,
+	"while":   WhileToken,
+	"with":    WithToken,
+	"yield":   YieldToken,
 }
 
 // Creates a new scanner.
 func NewScanner(source string) *Scanner {
+	return NewScannerAtLine(source, 1)
+}
+
+// Creates a new scanner for source that starts at the given line. The REPL
+// uses this to keep numbering lines across inputs.
+func NewScannerAtLine(source string, line int) *Scanner {
 	return &Scanner{
 		source:  source,
 		start:   0,
 		current: 0,
-		line:    1,
+		line:    line,
 	}
 }
 
 type Scanner struct {
 	// Raw source code.
 	source string
-	// First character in lexeme being scanned.
+	// Byte offset of the first character in lexeme being scanned.
 	start int
-	// Character currently being scanned.
+	// Byte offset of the character currently being scanned.
 	current int
 	// What source line `current` is on. Used to assign line location to tokens.
 	line int
 	// Scanned tokens.
 	tokens []Token
+	// Lines of `///` doc comments waiting to be attached to the next token.
+	doc []string
+	// Whether line ends terminate statements, making semicolons optional.
+	optionalSemicolons bool
 }
 
 // Returns an ordered list of Tokens from scanning the source.
@@ -54,11 +77,13 @@ func (s *Scanner) ScanTokens() []Token {
 		s.scanToken()
 	}
 
+	s.terminateLine()
 	s.tokens = append(s.tokens, Token{
 		TokenType: EOFToken,
 		Lexeme:    "",
 		Literal:   nil,
 		Line:      s.line,
+		Offset:    s.current,
 	})
 	return s.tokens
 }
@@ -78,15 +103,61 @@ func (s *Scanner) scanToken() {
 	case ',':
 		s.addToken(CommaToken)
 	case '.':
-		s.addToken(DotToken)
+		if s.peek() == '.' && s.peekNext() == '.' {
+			s.advance()
+			s.advance()
+			s.addToken(DotDotDotToken)
+		} else {
+			s.addToken(DotToken)
+		}
 	case '-':
-		s.addToken(MinusToken)
+		if s.match('-') {
+			s.addToken(MinusMinusToken)
+		} else if s.match('=') {
+			s.addToken(MinusEqualToken)
+		} else {
+			s.addToken(MinusToken)
+		}
 	case '+':
-		s.addToken(PlusToken)
+		if s.match('+') {
+			s.addToken(PlusPlusToken)
+		} else if s.match('=') {
+			s.addToken(PlusEqualToken)
+		} else {
+			s.addToken(PlusToken)
+		}
 	case ';':
 		s.addToken(SemicolonToken)
 	case '*':
-		s.addToken(StarToken)
+		if s.match('*') {
+			s.addToken(StarStarToken)
+		} else if s.match('=') {
+			s.addToken(StarEqualToken)
+		} else {
+			s.addToken(StarToken)
+		}
+	case '%':
+		s.addToken(PercentToken)
+	case '&':
+		s.addToken(AmpersandToken)
+	case '|':
+		s.addToken(PipeToken)
+	case '^':
+		s.addToken(CaretToken)
+	case '~':
+		s.addToken(TildeToken)
+	case '?':
+		if s.match('.') {
+			s.addToken(QuestionDotToken)
+		} else if s.match('?') {
+			s.addToken(QuestionQuestionToken)
+		} else {
+			s.addToken(QuestionToken)
+		}
+	case ':':
+		s.addToken(ColonToken)
+	case '#':
+		s.scanPrivateIdentifier()
 	case '!':
 		if s.match('=') {
 			s.addToken(BangEqualToken)
@@ -96,27 +167,34 @@ func (s *Scanner) scanToken() {
 	case '=':
 		if s.match('=') {
 			s.addToken(EqualEqualToken)
+		} else if s.match('>') {
+			s.addToken(FatArrowToken)
 		} else {
 			s.addToken(EqualToken)
 		}
 	case '<':
 		if s.match('=') {
 			s.addToken(LessEqualToken)
+		} else if s.match('<') {
+			s.addToken(LessLessToken)
 		} else {
 			s.addToken(LessToken)
 		}
 	case '>':
 		if s.match('=') {
 			s.addToken(GreaterEqualToken)
+		} else if s.match('>') {
+			s.addToken(GreaterGreaterToken)
 		} else {
 			s.addToken(GreaterToken)
 		}
 	case '/':
 		if s.match('/') {
-			// Comment goes until the end of the line.
-			for s.peek() != '\n' && !s.isAtEnd() {
-				s.advance()
-			}
+			s.scanLineComment()
+		} else if s.match('*') {
+			s.scanBlockComment()
+		} else if s.match('=') {
+			s.addToken(SlashEqualToken)
 		} else {
 			s.addToken(SlashToken)
 		}
@@ -125,6 +203,7 @@ func (s *Scanner) scanToken() {
 	case '\t':
 		break
 	case '\n':
+		s.terminateLine()
 		s.line++
 	case '"':
 		s.scanString()
@@ -134,15 +213,77 @@ func (s *Scanner) scanToken() {
 		// print(-123.abs()) will give you -123 since -123 is considered as negation
 		// applied to the number 123 and -123.abs() is really -(123.abs()).
 		if isDigit(c) {
-			s.scanNumber()
+			s.scanNumber(c)
 		} else if isAlpha(c) {
 			s.scanIdentifier()
+		} else if c == utf8.RuneError {
+			printErr(s.line, "Invalid UTF-8 encoding.")
 		} else {
 			printErr(s.line, fmt.Sprintf("Unexpected character: %c.", c))
 		}
 	}
 }
 
+// Comment goes until the end of the line. Comments starting with exactly
+// three slashes are doc comments, which are attached to the next token.
+func (s *Scanner) scanLineComment() {
+	isDoc := s.peek() == '/' && s.peekNext() != '/'
+	for s.peek() != '\n' && !s.isAtEnd() {
+		s.advance()
+	}
+	if isDoc {
+		text := s.source[s.start+3 : s.current]
+		text = strings.TrimSuffix(text, "\r")
+		s.doc = append(s.doc, strings.TrimPrefix(text, " "))
+	}
+}
+
+// Block comments go until the matching `*/` and may nest.
+func (s *Scanner) scanBlockComment() {
+	line := s.line
+	depth := 1
+	for depth > 0 {
+		if s.isAtEnd() {
+			printErr(line, "Unterminated block comment.")
+			return
+		}
+		switch c := s.advance(); {
+		case c == '\n':
+			s.terminateLine()
+			s.line++
+		case c == '/' && s.peek() == '*':
+			s.advance()
+			depth++
+		case c == '*' && s.peek() == '/':
+			s.advance()
+			depth--
+		}
+	}
+}
+
+// In optional semicolon mode, a line end terminates the statement if the
+// line's last token can end one, following Go's rules. The terminator is a
+// semicolon token with "\n" as its lexeme.
+func (s *Scanner) terminateLine() {
+	if !s.optionalSemicolons || len(s.tokens) == 0 {
+		return
+	}
+	switch s.tokens[len(s.tokens)-1].TokenType {
+	case IdentifierToken, PrivateIdentifierToken, NumberToken, StringToken, TrueToken, FalseToken,
+		NilToken, ThisToken, ReturnToken, YieldToken, RightParenToken, RightBraceToken,
+		PlusPlusToken, MinusMinusToken:
+		// Not added with addToken so that pending doc comments are kept for
+		// the next real token.
+		s.tokens = append(s.tokens, Token{
+			TokenType: SemicolonToken,
+			Lexeme:    "\n",
+			Literal:   nil,
+			Line:      s.line,
+			Offset:    s.current,
+		})
+	}
+}
+
 func (s *Scanner) scanString() {
 	for s.peek() != '"' && !s.isAtEnd() {
 		// glox supports strings.
@@ -160,40 +301,134 @@ func (s *Scanner) scanString() {
 	// Closing '"'
 	s.advance()
 	// Trim the surround quotes
-	value := string([]rune(s.source)[s.start+1 : s.current-1])
+	value := s.source[s.start+1 : s.current-1]
 	s.addTokenWithLiteral(StringToken, value)
 }
 
-func (s *Scanner) scanNumber() {
-	// Consume the rest of the digits.
-	for isDigit(s.peek()) {
-		s.advance()
+// Number literals are decimal integers (`123`), floats (`1.5`, `6.02E23`,
+// `1e-9`) or prefixed integers in hexadecimal (`0xFF`), binary (`0b1010`) or
+// octal (`0o755`). Underscores may separate digits (`1_000_000`).
+// Malformed literals are reported and no token is added.
+func (s *Scanner) scanNumber(first rune) {
+	if first == '0' {
+		switch s.peek() {
+		case 'x', 'X':
+			s.scanPrefixedInteger(16, "hexadecimal")
+			return
+		case 'b', 'B':
+			s.scanPrefixedInteger(2, "binary")
+			return
+		case 'o', 'O':
+			s.scanPrefixedInteger(8, "octal")
+			return
+		}
 	}
 
+	// The first digit has already been consumed.
+	if !s.scanDigits(10, "decimal") {
+		return
+	}
+	isFloat := false
 	// Check for the fractional part.
 	if s.peek() == '.' && isDigit(s.peekNext()) {
+		isFloat = true
+		// Consume the '.' and the first fractional digit.
+		s.advance()
+		s.advance()
+		if !s.scanDigits(10, "decimal") {
+			return
+		}
+	}
+	// Check for the exponent.
+	if s.peek() == 'e' || s.peek() == 'E' {
+		isFloat = true
 		s.advance()
-		// Consume the '.'
-		for isDigit(s.peek()) {
+		if s.peek() == '+' || s.peek() == '-' {
 			s.advance()
 		}
+		if !isDigit(s.peek()) {
+			s.malformedNumber("Expect digits in exponent of number literal.")
+			return
+		}
+		s.advance()
+		if !s.scanDigits(10, "decimal") {
+			return
+		}
+	}
+	if isAlphaNumeric(s.peek()) {
+		s.malformedNumber(fmt.Sprintf("Unexpected character '%c' in number literal.", s.peek()))
+		return
 	}
 
-	text := string([]rune(s.source)[s.start:s.current])
+	text := strings.ReplaceAll(s.source[s.start:s.current], "_", "")
+	if !isFloat {
+		n, _ := parseInteger(text, 10)
+		s.addTokenWithLiteral(NumberToken, n)
+		return
+	}
 	n, err := strconv.ParseFloat(text, 64)
 	if err != nil {
-		printErr(s.line, fmt.Sprintf("Unable to parse string: %v.", err))
+		s.malformedNumber(fmt.Sprintf("Number literal '%s' is out of range.", text))
+		return
+	}
+	s.addTokenWithLiteral(NumberToken, n)
+}
+
+// Scans an integer literal after its leading '0', e.g. 0xFF.
+func (s *Scanner) scanPrefixedInteger(base int, name string) {
+	// Consume the base character.
+	prefix := string([]rune{'0', s.advance()})
+	if !isDigitInBase(s.peek(), base) {
+		s.malformedNumber(fmt.Sprintf("Expect %s digits after '%s'.", name, prefix))
+		return
+	}
+	s.advance()
+	if !s.scanDigits(base, name) {
+		return
+	}
+	if isAlphaNumeric(s.peek()) {
+		s.malformedNumber(fmt.Sprintf("Invalid digit '%c' in %s literal.", s.peek(), name))
+		return
 	}
+	text := strings.ReplaceAll(s.source[s.start+2:s.current], "_", "")
+	n, _ := parseInteger(text, base)
 	s.addTokenWithLiteral(NumberToken, n)
 }
 
+// Consumes digits in the given base, allowing single underscores between
+// them. The digit before the current position must already be consumed.
+// Returns false if the digits are malformed, after reporting the error.
+func (s *Scanner) scanDigits(base int, name string) bool {
+	for {
+		if s.peek() == '_' {
+			if !isDigitInBase(s.peekNext(), base) {
+				s.malformedNumber(fmt.Sprintf("Digit separator '_' must be followed by a %s digit.", name))
+				return false
+			}
+			s.advance()
+		}
+		if !isDigitInBase(s.peek(), base) {
+			return true
+		}
+		s.advance()
+	}
+}
+
+// Reports a malformed number literal and skips the rest of it so scanning
+// can resume after the literal.
+func (s *Scanner) malformedNumber(message string) {
+	printErr(s.line, message)
+	for isAlphaNumeric(s.peek()) {
+		s.advance()
+	}
+}
+
 func (s *Scanner) scanIdentifier() {
 	for isAlphaNumeric(s.peek()) {
 		s.advance()
 	}
 
-	// TODO: Refactor substring conversion into its own function.
-	text := string([]rune(s.source)[s.start:s.current])
+	text := s.source[s.start:s.current]
 	tokenType, ok := reservedWords[text]
 	if !ok {
 		tokenType = IdentifierToken
@@ -201,33 +436,38 @@ func (s *Scanner) scanIdentifier() {
 	s.addToken(tokenType)
 }
 
-func (s *Scanner) match(expected rune) bool {
-	if s.isAtEnd() {
-		return false
+// Private member names are identifiers prefixed with '#', e.g. `#count`.
+func (s *Scanner) scanPrivateIdentifier() {
+	if !isAlpha(s.peek()) {
+		printErr(s.line, "Expect a name after '#'.")
+		return
+	}
+	for isAlphaNumeric(s.peek()) {
+		s.advance()
 	}
-	// TODO: Figure out how efficient this []rune cast is.
-	currRune := []rune(s.source)[s.current]
-	if currRune != expected {
+	s.addToken(PrivateIdentifierToken)
+}
+
+func (s *Scanner) match(expected rune) bool {
+	c, size := s.decode(s.current)
+	if size == 0 || c != expected {
 		return false
 	}
-	s.current++
+	s.current += size
 	return true
 }
 
 func (s *Scanner) peek() rune {
-	if s.isAtEnd() {
-		return '\000'
-	}
-	return []rune(s.source)[s.current]
+	c, _ := s.decode(s.current)
+	return c
 }
 
 // peekNext does lookahead by 2 characters. It is useful when parsing decimals.
 // We don't want to consume a '.' unless we're sure it is followed by a digit.
 func (s *Scanner) peekNext() rune {
-	if s.current+1 >= len(s.source) {
-		return '\000'
-	}
-	return []rune(s.source)[s.current+1]
+	_, size := s.decode(s.current)
+	c, _ := s.decode(s.current + size)
+	return c
 }
 
 // isAtEnd checks whether we have consumed all characters in `source`.
@@ -237,32 +477,60 @@ func (s *Scanner) isAtEnd() bool {
 
 // advance consumes and returns the next character.
 func (s *Scanner) advance() rune {
-	// TODO: This advances by one rune?
-	c := []rune(s.source)[s.current]
-	s.current++
+	c, size := s.decode(s.current)
+	s.current += size
 	return c
 }
 
+// Decodes the character starting at byte offset i. Returns '\000' and a size
+// of 0 at the end of the source. Invalid UTF-8 decodes to utf8.RuneError with
+// a size of 1.
+func (s *Scanner) decode(i int) (rune, int) {
+	if i >= len(s.source) {
+		return '\000', 0
+	}
+	if c := s.source[i]; c < utf8.RuneSelf {
+		return rune(c), 1
+	}
+	return utf8.DecodeRuneInString(s.source[i:])
+}
+
 func (s *Scanner) addToken(t TokenType) {
 	s.addTokenWithLiteral(t, nil)
 }
 
 func (s *Scanner) addTokenWithLiteral(t TokenType, literal interface{}) {
-	text := []rune(s.source)[s.start:s.current]
 	s.tokens = append(s.tokens, Token{
 		TokenType: t,
-		Lexeme:    string(text),
+		Lexeme:    s.source[s.start:s.current],
 		Literal:   literal,
 		Line:      s.line,
+		Offset:    s.start,
+		Doc:       strings.Join(s.doc, "\n"),
 	})
+	s.doc = nil
 }
 
 func isDigit(c rune) bool {
 	return c >= '0' && c <= '9'
 }
 
+func isDigitInBase(c rune, base int) bool {
+	switch base {
+	case 2:
+		return c == '0' || c == '1'
+	case 8:
+		return c >= '0' && c <= '7'
+	case 16:
+		return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+	}
+	return isDigit(c)
+}
+
+// Identifiers may contain letters from any script, e.g. `café` or `π`.
 func isAlpha(c rune) bool {
-	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_'
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_' ||
+		(c >= utf8.RuneSelf && unicode.IsLetter(c))
 }
 
 func isAlphaNumeric(c rune) bool {
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Builds a source of at least size bytes out of declarations with non-ASCII
+// identifiers, strings and comments.
+func multibyteSource(size int) string {
+	var source strings.Builder
+	for i := 0; source.Len() < size; i++ {
+		fmt.Fprintf(&source, "/// Größe der Würfel №%d, 数字 ✓.\n", i)
+		fmt.Fprintf(&source, "var größe_%d = %d * 3.5 + 0xff; // π ≈ 3.14159 🙂\n", i, i)
+		fmt.Fprintf(&source, "/* Καλημέρα, κόσμε /* вложенный */ 世界 */\n")
+		fmt.Fprintf(&source, "print \"héllo, wörld — こんにちは %d\" + größe_%d;\n", i, i)
+	}
+	return source.String()
+}
+
+func BenchmarkScanTokens(b *testing.B) {
+	for _, size := range []int{1 << 20, 2 << 20, 4 << 20, 8 << 20} {
+		source := multibyteSource(size)
+		b.Run(fmt.Sprintf("%dMB", size>>20), func(b *testing.B) {
+			b.SetBytes(int64(len(source)))
+			for i := 0; i < b.N; i++ {
+				NewScanner(source).ScanTokens()
+			}
+		})
+	}
+}
@@ -3,12 +3,17 @@ package main
 type StmtVisitor interface {
 	VisitBlockStmt(stmt BlockStmt) (any, error)
 	VisitClassStmt(stmt ClassStmt) (any, error)
+	VisitDestructureStmt(stmt DestructureStmt) (any, error)
 	VisitExpressionStmt(stmt ExpressionStmt) (any, error)
+	VisitForInStmt(stmt ForInStmt) (any, error)
 	VisitFunctionStmt(stmt FunctionStmt) (any, error)
 	VisitIfStmt(stmt IfStmt) (any, error)
+	VisitMultiAssignStmt(stmt MultiAssignStmt) (any, error)
+	VisitMatchStmt(stmt MatchStmt) (any, error)
 	VisitPrintStmt(stmt PrintStmt) (any, error)
 	VisitVarStmt(stmt VarStmt) (any, error)
 	VisitReturnStmt(stmt ReturnStmt) (any, error)
+	VisitTraitStmt(stmt TraitStmt) (any, error)
 	VisitWhileStmt(stmt WhileStmt) (any, error)
 }
 
@@ -27,13 +32,28 @@ func (expr BlockStmt) AcceptStmt(visitor StmtVisitor) (any, error) {
 type ClassStmt struct {
 	Name       Token
 	Superclass VariableExpr
+	Traits     []VariableExpr
+	Data       bool
+	Fields     []Param
 	Methods    []FunctionStmt
+	Doc        string
 }
 
 func (expr ClassStmt) AcceptStmt(visitor StmtVisitor) (any, error) {
 	return visitor.VisitClassStmt(expr)
 }
 
+type DestructureStmt struct {
+	Keyword Token
+	Names   []Token
+	Fields  []Token
+	Values  []Expr
+}
+
+func (expr DestructureStmt) AcceptStmt(visitor StmtVisitor) (any, error) {
+	return visitor.VisitDestructureStmt(expr)
+}
+
 type ExpressionStmt struct {
 	Expression Expr
 }
@@ -42,10 +62,26 @@ func (expr ExpressionStmt) AcceptStmt(visitor StmtVisitor) (any, error) {
 	return visitor.VisitExpressionStmt(expr)
 }
 
+type ForInStmt struct {
+	Keyword  Token
+	Name     Token
+	Iterable Expr
+	Body     Stmt
+}
+
+func (expr ForInStmt) AcceptStmt(visitor StmtVisitor) (any, error) {
+	return visitor.VisitForInStmt(expr)
+}
+
 type FunctionStmt struct {
-	Name   Token
-	Params []Token
-	Body   []Stmt
+	Name       Token
+	Params     []Param
+	ReturnType Token
+	Abstract   bool
+	Async      bool
+	Generator  bool
+	Body       []Stmt
+	Doc        string
 }
 
 func (expr FunctionStmt) AcceptStmt(visitor StmtVisitor) (any, error) {
@@ -62,6 +98,26 @@ func (expr IfStmt) AcceptStmt(visitor StmtVisitor) (any, error) {
 	return visitor.VisitIfStmt(expr)
 }
 
+type MultiAssignStmt struct {
+	Targets []Expr
+	Equals  Token
+	Values  []Expr
+}
+
+func (expr MultiAssignStmt) AcceptStmt(visitor StmtVisitor) (any, error) {
+	return visitor.VisitMultiAssignStmt(expr)
+}
+
+type MatchStmt struct {
+	Keyword Token
+	Subject Expr
+	Cases   []MatchCase
+}
+
+func (expr MatchStmt) AcceptStmt(visitor StmtVisitor) (any, error) {
+	return visitor.VisitMatchStmt(expr)
+}
+
 type PrintStmt struct {
 	Expression Expr
 }
@@ -71,8 +127,11 @@ func (expr PrintStmt) AcceptStmt(visitor StmtVisitor) (any, error) {
 }
 
 type VarStmt struct {
+	Keyword     Token
 	Name        Token
+	Type        Token
 	Initializer Expr
+	Doc         string
 }
 
 func (expr VarStmt) AcceptStmt(visitor StmtVisitor) (any, error) {
@@ -88,6 +147,16 @@ func (expr ReturnStmt) AcceptStmt(visitor StmtVisitor) (any, error) {
 	return visitor.VisitReturnStmt(expr)
 }
 
+type TraitStmt struct {
+	Name    Token
+	Methods []FunctionStmt
+	Doc     string
+}
+
+func (expr TraitStmt) AcceptStmt(visitor StmtVisitor) (any, error) {
+	return visitor.VisitTraitStmt(expr)
+}
+
 type WhileStmt struct {
 	Condition Expr
 	Body      Stmt
@@ -7,10 +7,16 @@ type Token struct {
 	TokenType TokenType
 	// Raw substring that forms the token.
 	Lexeme string
-	// The parsed representation of literal values e.g. float64, string.
+	// The parsed representation of literal values e.g. float64, int64, string.
 	Literal interface{}
 	// Line where the token was scanned.
 	Line int
+	// Offset of the lexeme in the source. Together with Line, this makes every
+	// scanned token unique, which the interpreter relies on to key resolved
+	// variables by their AST node.
+	Offset int
+	// Text of the `///` doc comments directly preceding the token, if any.
+	Doc string
 }
 
 func (t Token) String() string {
@@ -15,8 +15,25 @@ const (
 	SemicolonToken
 	SlashToken
 	StarToken
+	PercentToken
+	AmpersandToken
+	PipeToken
+	CaretToken
+	TildeToken
+	QuestionToken
+	ColonToken
 
-	// One or two character tokens.
+	// One, two or three character tokens.
+	DotDotDotToken
+	StarStarToken
+	PlusPlusToken
+	MinusMinusToken
+	PlusEqualToken
+	MinusEqualToken
+	StarEqualToken
+	SlashEqualToken
+	QuestionDotToken
+	QuestionQuestionToken
 	BangToken
 	BangEqualToken
 	EqualToken
@@ -25,29 +42,43 @@ const (
 	GreaterEqualToken
 	LessToken
 	LessEqualToken
+	FatArrowToken
+	LessLessToken
+	GreaterGreaterToken
 
 	// Literals.
 	IdentifierToken
+	PrivateIdentifierToken
 	StringToken
 	NumberToken
 
 	// Keywords
 	AndToken
+	AsyncToken
+	AwaitToken
+	CaseToken
 	ClassToken
+	ConstToken
+	DefaultToken
 	ElseToken
 	FalseToken
 	FunToken
 	ForToken
 	IfToken
+	IsToken
+	MatchToken
 	NilToken
 	OrToken
 	PrintToken
 	ReturnToken
 	SuperToken
 	ThisToken
+	TraitToken
 	TrueToken
 	VarToken
 	WhileToken
+	WithToken
+	YieldToken
 
 	EOFToken
 )
@@ -76,6 +107,40 @@ func (t TokenType) String() string {
 		return "Slash"
 	case StarToken:
 		return "Star"
+	case PercentToken:
+		return "Percent"
+	case AmpersandToken:
+		return "Ampersand"
+	case PipeToken:
+		return "Pipe"
+	case CaretToken:
+		return "Caret"
+	case TildeToken:
+		return "Tilde"
+	case QuestionToken:
+		return "Question"
+	case ColonToken:
+		return "Colon"
+	case DotDotDotToken:
+		return "DotDotDot"
+	case StarStarToken:
+		return "StarStar"
+	case PlusPlusToken:
+		return "PlusPlus"
+	case MinusMinusToken:
+		return "MinusMinus"
+	case PlusEqualToken:
+		return "PlusEqual"
+	case MinusEqualToken:
+		return "MinusEqual"
+	case StarEqualToken:
+		return "StarEqual"
+	case SlashEqualToken:
+		return "SlashEqual"
+	case QuestionDotToken:
+		return "QuestionDot"
+	case QuestionQuestionToken:
+		return "QuestionQuestion"
 	case BangToken:
 		return "Bang"
 	case BangEqualToken:
@@ -92,16 +157,34 @@ func (t TokenType) String() string {
 		return "Less"
 	case LessEqualToken:
 		return "LessEqual"
+	case FatArrowToken:
+		return "FatArrow"
+	case LessLessToken:
+		return "LessLess"
+	case GreaterGreaterToken:
+		return "GreaterGreater"
 	case IdentifierToken:
 		return "Identifier"
+	case PrivateIdentifierToken:
+		return "PrivateIdentifier"
 	case StringToken:
 		return "String"
 	case NumberToken:
 		return "Number"
 	case AndToken:
 		return "And"
+	case AsyncToken:
+		return "Async"
+	case AwaitToken:
+		return "Await"
+	case CaseToken:
+		return "Case"
 	case ClassToken:
 		return "Class"
+	case ConstToken:
+		return "Const"
+	case DefaultToken:
+		return "Default"
 	case ElseToken:
 		return "Else"
 	case FalseToken:
@@ -112,6 +195,10 @@ func (t TokenType) String() string {
 		return "For"
 	case IfToken:
 		return "If"
+	case IsToken:
+		return "Is"
+	case MatchToken:
+		return "Match"
 	case NilToken:
 		return "Nil"
 	case OrToken:
@@ -124,12 +211,18 @@ func (t TokenType) String() string {
 		return "Super"
 	case ThisToken:
 		return "This"
+	case TraitToken:
+		return "Trait"
 	case TrueToken:
 		return "True"
 	case VarToken:
 		return "Var"
 	case WhileToken:
 		return "While"
+	case WithToken:
+		return "With"
+	case YieldToken:
+		return "Yield"
 	case EOFToken:
 		return "EOF"
 	default:
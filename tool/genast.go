@@ -106,29 +106,37 @@ func main() {
 	// Define the AST.
 	dir := os.Args[1]
 	defineAst(dir, "Expr", []string{
-		"Assign   : Name Token, Value Expr",
+		"Assign   : Name Token, Operator Token, Value Expr, Postfix bool",
+		"Await    : Keyword Token, Value Expr",
 		"Binary   : Left Expr, Operator Token, Right Expr",
-		"Call     : Callee Expr, Paren Token, Arguments []Expr",
-		"Get      : Object Expr, Name Token",
+		"Call     : Callee Expr, Paren Token, Arguments []Expr, Names []Token",
+		"Conditional : Condition Expr, ThenBranch Expr, ElseBranch Expr",
+		"Get      : Object Expr, Name Token, Optional bool",
 		"Grouping : Expression Expr",
 		"Literal  : Value interface{}",
 		"Logical  : Left Expr, Operator Token, Right Expr",
-		"Set      : Object Expr, Name Token, Value Expr",
+		"Set      : Object Expr, Name Token, Operator Token, Value Expr, Postfix bool",
 		"Super    : Keyword Token, Method Token",
 		"This     : Keyword Token",
 		"Unary    : Operator Token, Right Expr",
 		"Variable : Name Token",
+		"Yield    : Keyword Token, Value Expr",
 	})
 
 	defineAst(dir, "Stmt", []string{
 		"Block      : Statements []Stmt",
-		"Class      : Name Token, Superclass VariableExpr, Methods []FunctionStmt",
+		"Class      : Name Token, Superclass VariableExpr, Traits []VariableExpr, Data bool, Fields []Param, Methods []FunctionStmt, Doc string",
+		"Destructure : Keyword Token, Names []Token, Fields []Token, Values []Expr",
 		"Expression : Expression Expr",
-		"Function   : Name Token, Params []Token, Body []Stmt",
+		"ForIn      : Keyword Token, Name Token, Iterable Expr, Body Stmt",
+		"Function   : Name Token, Params []Param, ReturnType Token, Abstract bool, Async bool, Generator bool, Body []Stmt, Doc string",
 		"If         : Condition Expr, ThenBranch Stmt, ElseBranch Stmt",
+		"MultiAssign : Targets []Expr, Equals Token, Values []Expr",
+		"Match      : Keyword Token, Subject Expr, Cases []MatchCase",
 		"Print      : Expression Expr",
-		"Var        : Name Token, Initializer Expr",
+		"Var        : Keyword Token, Name Token, Type Token, Initializer Expr, Doc string",
 		"Return     : Keyword Token, Value Expr",
+		"Trait      : Name Token, Methods []FunctionStmt, Doc string",
 		"While      : Condition Expr, Body Stmt",
 	})
 	formatFiles()
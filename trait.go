@@ -0,0 +1,23 @@
+package main
+
+import "fmt"
+
+// Runtime representation of a glox trait, a set of methods that classes mix
+// in with `with`.
+type Trait struct {
+	// Name of the trait.
+	Name string
+	// Methods copied into the classes using the trait.
+	Methods map[string]*Function
+	// Documentation from the trait's doc comments.
+	Doc string
+}
+
+// Creates a new trait.
+func NewTrait(name string, methods map[string]*Function) *Trait {
+	return &Trait{Name: name, Methods: methods}
+}
+
+func (t *Trait) String() string {
+	return fmt.Sprintf("<trait %s>", t.Name)
+}